@@ -0,0 +1,70 @@
+//+build windows
+
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/b-2019-apt-test/divider/pkg/atomicfile"
+)
+
+// partialSuffix marks output files which are not complete.
+const partialSuffix = ".partial"
+
+// pendingFile is an output file written as path.partial and renamed to path
+// once the run succeeds, so that path never holds incomplete output and the
+// previous one stays untouched until then. The partial file is kept if the
+// run fails or stops early; a resumed run continues it.
+type pendingFile struct {
+	*os.File
+	path string
+}
+
+// openPending opens path.partial with the flags.
+func openPending(path string, flag int) (*pendingFile, error) {
+	f, err := os.OpenFile(path+partialSuffix, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &pendingFile{File: f, path: path}, nil
+}
+
+// finish syncs and closes the file. If commit is set, the file replaces
+// path, otherwise it is kept as partial.
+func (f *pendingFile) finish(commit bool) error {
+	err := f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || !commit {
+		return err
+	}
+	return os.Rename(f.Name(), f.path)
+}
+
+// finishPending finishes the files and reports whether all of them are
+// saved. Files of disabled outputs are kept as partial even if the run
+// succeeded.
+func finishPending(logger *slog.Logger, files []*pendingFile, ok bool, disabled map[string]error) bool {
+	saved := true
+	for _, f := range files {
+		commit := ok && disabled[f.path] == nil
+		if err := f.finish(commit); err != nil {
+			logger.Error("failed to save output", "path", f.path, "error", err)
+			saved = false
+		} else if !commit {
+			logger.Warn("output is incomplete", "path", f.Name())
+		}
+	}
+	return saved
+}
+
+// writeFile replaces path with data atomically.
+func writeFile(path string, data []byte) error {
+	return atomicfile.Write(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
@@ -0,0 +1,153 @@
+//+build windows
+
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/cluster"
+	"github.com/b-2019-apt-test/divider/internal/divider/csvrep"
+)
+
+// coordinate leases jobs of the jobs files to agents, writes their results
+// and returns the exit code.
+func coordinate(args []string) int {
+
+	fs := flag.NewFlagSet("coordinator", flag.ExitOnError)
+	var in inputFlags
+	fs.Var(&in, "i", "path or glob pattern of the files with jobs, repeatable")
+	fs.BoolVar(&concurrentInputs, "concurrent-inputs", false, "read the files with jobs concurrently instead of one by one")
+	output := fs.String("o", "divider.csv", "results file path")
+	listen := fs.String("listen", ":7070", "address to accept agents on")
+	batch := fs.Int("batch", cluster.DefaultBatchSize, "count of jobs leased to an agent at once")
+	leaseTimeout := fs.Duration("lease", cluster.DefaultLeaseTimeout, "time an agent has to complete a batch before it is leased again")
+	fs.BoolVar(&reasonColumn, "reason", false, "add reason column to results file")
+	fs.BoolVar(&sourceColumns, "source", false, "add source and index columns with the jobs file and the job number in it to results file")
+	fs.BoolVar(&inputIDColumn, "input-id", false, "add input_id column with the job ID given in the jobs file to results file")
+	logFlags(fs)
+	fs.Parse(args)
+
+	if len(in) == 0 || len(fs.Args()) != 0 || *batch < 1 || *leaseTimeout <= 0 || checkLogFlags() != nil {
+		fs.Usage()
+		return exitUsage
+	}
+
+	logger, closeLog := newLogger()
+	defer closeLog()
+
+	paths, err := in.expand()
+	if err != nil {
+		return fail(err)
+	}
+	readers, provider, _, err := openInputs(paths, divider.Checkpoint{})
+	if err != nil {
+		return fail(err)
+	}
+	defer closeFiles(readers)
+	defer provider.Close()
+
+	writer, err := openPending(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fail(err)
+	}
+	reporter, err := csvrep.New(writer, csvOptions()...)
+	if err != nil {
+		finishPending(logger, []*pendingFile{writer}, false, nil)
+		return fail(err)
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		finishPending(logger, []*pendingFile{writer}, false, nil)
+		return fail(err)
+	}
+	logger.Info("waiting for agents", "address", ln.Addr().String())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	summary, err := cluster.NewCoordinator(provider, reporter).
+		SetBatchSize(*batch).
+		SetLeaseTimeout(*leaseTimeout).
+		SetLogger(logger).
+		Serve(ctx, ln)
+
+	// A stopped run can not be resumed, its output stays partial.
+	if err != nil {
+		finishPending(logger, []*pendingFile{writer}, false, nil)
+		if ctx.Err() != nil {
+			logger.Info("coordinator stopped")
+			logSummary(logger, summary)
+			return exitOK
+		}
+		logger.Error("coordinator failed", "error", err)
+		logSummary(logger, summary)
+		return exitCode(err)
+	}
+	if !finishPending(logger, []*pendingFile{writer}, true, nil) {
+		return exitReporter
+	}
+	logger.Info("processing complete")
+	logSummary(logger, summary)
+	return exitOK
+}
+
+// agent divides jobs leased by the coordinator and returns the exit code.
+func agent(args []string) int {
+
+	fs := flag.NewFlagSet("agent", flag.ExitOnError)
+	addr := fs.String("connect", "localhost:7070", "address of the coordinator")
+	wait := fs.Duration("wait", 10*time.Second, "time to wait for the coordinator to accept connection")
+	m := fs.String("m", "syscall", "division method: go, cgo, syscall")
+	logFlags(fs)
+	fs.Parse(args)
+
+	d := parseDivider(*m)
+	if d == nil || len(fs.Args()) != 0 || checkLogFlags() != nil {
+		fs.Usage()
+		return exitUsage
+	}
+
+	logger, closeLog := newLogger()
+	defer closeLog()
+	logger = logger.With("method", *m)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var nc net.Conn
+	var err error
+	for deadline := time.Now().Add(*wait); ; {
+		if nc, err = net.Dial("tcp", *addr); err == nil || time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return exitOK
+		}
+	}
+	if err != nil {
+		return fail(err)
+	}
+	defer nc.Close()
+	logger.Info("connected", "coordinator", *addr)
+
+	err = cluster.NewAgent(d).SetLogger(logger).Run(ctx, nc)
+	switch {
+	case ctx.Err() != nil:
+		logger.Info("agent stopped")
+	case err != nil:
+		logger.Error("agent failed", "error", err)
+		return exitFailure
+	default:
+		logger.Info("agent done")
+	}
+	return exitOK
+}
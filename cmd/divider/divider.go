@@ -3,57 +3,124 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
 	"time"
 
 	"github.com/b-2019-apt-test/divider/internal/divider"
-	"github.com/b-2019-apt-test/divider/internal/divider/csvrep"
-	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
+	"github.com/b-2019-apt-test/divider/internal/divider/metrics"
 
 	"github.com/b-2019-apt-test/divider/pkg/div"
 	"github.com/b-2019-apt-test/divider/pkg/div/calldiv"
 	"github.com/b-2019-apt-test/divider/pkg/div/cgodiv"
 	"github.com/b-2019-apt-test/divider/pkg/div/godiv"
+	"github.com/b-2019-apt-test/divider/pkg/div/limitdiv"
+	"github.com/b-2019-apt-test/divider/pkg/div/timeoutdiv"
+	"github.com/b-2019-apt-test/divider/pkg/ratelimit"
 )
 
 var (
-	jobsFilePath    string
-	resultsFilePath string
-	logFilePath     string
 	workers         uint
+	chunkSize       uint
+	workStealing    bool
 	dontUseExt      bool
 	method          string
-
-	start = time.Now()
+	rate            float64
+	burst           uint
+	summaryFilePath string
+	checkpointPath  string
+	checkpointEvery time.Duration
+	resume          bool
+	deadLetterPath  string
+	reasonColumn    bool
+	metricsAddr     string
+	metricsFilePath string
+	thresholds      divider.Thresholds
+	maxDuration     time.Duration
+	jobTimeout      time.Duration
+	shard           shardFlag
 )
 
 func main() {
 
-	flag.StringVar(&jobsFilePath, "i", "", "path to the file with jobs")
-	flag.StringVar(&resultsFilePath, "o", "divider.csv", "results file path")
-	flag.StringVar(&logFilePath, "log", "", "log file path")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			os.Exit(replay(os.Args[2:]))
+		case "validate":
+			os.Exit(validateJobs(os.Args[2:]))
+		case "merge":
+			os.Exit(merge(os.Args[2:]))
+		case "coordinator":
+			os.Exit(coordinate(os.Args[2:]))
+		case "agent":
+			os.Exit(agent(os.Args[2:]))
+		}
+	}
+
+	flag.Var(&inputs, "i", "path or glob pattern of the files with jobs, repeatable")
+	flag.BoolVar(&concurrentInputs, "concurrent-inputs", false, "read the files with jobs concurrently instead of one by one")
+	flag.BoolVar(&sourceColumns, "source", false, "add source and index columns with the jobs file and the job number in it to results file")
+	flag.Var(&idPolicy, "id-policy", "policy for missing and duplicate job IDs: keep, auto, warn, reject")
+	flag.BoolVar(&inputIDColumn, "input-id", false, "add input_id column with the job ID given in the jobs file to results file")
+	flag.Var(&outputs, "o", "results file as [optional:][format:]path, repeatable; formats: csv (default), ndjson; failure of optional output does not stop the run (default divider.csv)")
+	logFlags(flag.CommandLine)
 	flag.UintVar(&workers, "w", uint(runtime.NumCPU()*1024), "workers count")
+	flag.UintVar(&chunkSize, "c", 64, "jobs per chunk passed to workers, 1 disables chunking")
+	flag.BoolVar(&workStealing, "s", false, "use work-stealing scheduler for workers")
 	flag.BoolVar(&dontUseExt, "z", false, "do not use math.dll (depricated)")
 	flag.StringVar(&method, "m", "syscall", "division method: go, cgo, syscall")
+	flag.Float64Var(&rate, "rate", 0, "max division calls per second, 0 means no limit")
+	flag.UintVar(&burst, "burst", 1, "max division calls in a burst above the rate")
+	flag.StringVar(&summaryFilePath, "summary", "", "run summary JSON file path")
+	flag.StringVar(&checkpointPath, "checkpoint", "", "checkpoint file path, default is the results file path with .checkpoint suffix")
+	flag.DurationVar(&checkpointEvery, "checkpoint-every", 5*time.Second, "interval between checkpoints, 0 saves it only at exit")
+	flag.BoolVar(&resume, "resume", false, "resume the interrupted run from the checkpoint")
+	flag.StringVar(&deadLetterPath, "deadletter", "", "dead-letter file path for failed jobs")
+	flag.BoolVar(&reasonColumn, "reason", false, "add reason column to results file")
+	flag.Var(&shard, "shard", "process only the shard i/n of jobs, results keep job IDs of the whole input")
+	flag.BoolVar(&shard.ByInputID, "shard-by-id", false, "assign jobs to shards by the hash of the job ID given in the jobs file")
+	flag.DurationVar(&maxDuration, "max-duration", 0, "stop gracefully once the run takes this long, 0 means no limit")
+	flag.DurationVar(&jobTimeout, "job-timeout", 0, "report a job invalid if its division takes longer, 0 means no limit")
+	flag.Uint64Var(&thresholds.MaxInvalid, "max-invalid", 0, "abort after this many invalid jobs, 0 means no limit")
+	flag.Float64Var(&thresholds.MaxInvalidRatio, "max-invalid-ratio", 0, "abort if the ratio of invalid jobs in the window exceeds this, 0 means no limit")
+	flag.UintVar(&thresholds.Window, "ratio-window", 1000, "count of the last jobs -max-invalid-ratio is checked over")
+	flag.Uint64Var(&thresholds.MaxConsecutiveDivErrors, "max-div-errors-in-row", 0, "abort after this many division errors in a row, 0 means no limit")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "address to serve Prometheus metrics at /metrics during the run, e.g. :9100")
+	flag.StringVar(&metricsFilePath, "metrics-file", "", "Prometheus textfile collector file path, written on exit")
+	flag.Var(&stages, "stage", "pre-processing stage, repeatable: drop:arg2=0, range:min:max, clamp:min:max, swap, normalize, dedup")
 	flag.Parse()
 
 	if len(flag.Args()) != 0 {
 		exitWithUsage("Unparsed args:", flag.Args())
 	}
 
-	if len(jobsFilePath) == 0 {
+	if len(inputs) == 0 {
 		exitWithUsage("Path to the jobs file not specified.")
 	}
 
-	var (
-		err error
-		d   div.Divider
-	)
+	if err := checkLogFlags(); err != nil {
+		exitWithUsage(err)
+	}
+
+	if len(outputs) == 0 {
+		outputs.Set("divider.csv")
+	}
+
+	if len(checkpointPath) == 0 {
+		checkpointPath = outputs[0].path + ".checkpoint"
+	}
+
+	var d div.Divider
 
 	if dontUseExt {
 		d = godiv.Divider
@@ -61,74 +128,240 @@ func main() {
 		exitWithUsage("Divider method unknown:", method)
 	}
 
-	logger := log.New(os.Stderr, "", log.LstdFlags)
-	if len(logFilePath) != 0 {
-		logFile, err := os.OpenFile(logFilePath,
-			os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-		} else {
-			logger.SetOutput(logFile)
-			defer closeSyncFile(logFile)
+	if jobTimeout > 0 {
+		d = timeoutdiv.New(d, jobTimeout)
+	}
+
+	var limiter *ratelimit.Limiter
+	if rate > 0 {
+		limiter = ratelimit.New(rate, burst)
+		d = limitdiv.New(d, limiter)
+	}
+
+	os.Exit(run(d, limiter))
+}
+
+// run processes jobs and returns the exit code. Files are synced and closed
+// before the return, even if processing failed.
+func run(d div.Divider, limiter *ratelimit.Limiter) int {
+
+	logger, closeLog := newLogger()
+	defer closeLog()
+	logger = logger.With("method", methodName())
+
+	var cp divider.Checkpoint
+	if resume {
+		var err error
+		if cp, err = divider.LoadCheckpoint(checkpointPath); err != nil {
+			return fail(err)
 		}
+		logger.Info("resuming", "next_id", cp.NextID, "checkpoint", checkpointPath)
 	}
 
-	reader, err := os.OpenFile(jobsFilePath, os.O_RDONLY, 0400)
-	exitOn(err)
-	defer closeFile(reader)
-	provider, err := jsonprov.New(reader)
-	exitOn(err)
+	paths, err := inputs.expand()
+	if err != nil {
+		return fail(err)
+	}
+	if resume && len(paths) != 1 {
+		return fail(fmt.Errorf("only a run with a single jobs file can be resumed, %d given", len(paths)))
+	}
+	if resume && concurrentInputs {
+		return fail(errors.New("a run with concurrent inputs can not be resumed"))
+	}
+	// Seen job IDs are not checkpointed, duplicates across the resume would
+	// be missed.
+	if resume && divider.IDPolicy(idPolicy) != divider.IDKeep {
+		return fail(fmt.Errorf("a run with %s ID policy can not be resumed", idPolicy.String()))
+	}
+	readers, provider, decoders, err := openInputs(paths, cp)
+	if err != nil {
+		return fail(err)
+	}
+	defer closeFiles(readers)
+	defer provider.Close()
 
-	writer, err := os.Create(resultsFilePath)
-	exitOn(err)
-	defer closeSyncFile(writer)
-	reporter, err := csvrep.New(writer)
-	exitOn(err)
+	pending, reporter, err := openOutputs(cp, logger)
+	if err != nil {
+		return fail(err)
+	}
+	finished := false
+	defer func() {
+		if !finished {
+			finishPending(logger, pending, false, nil)
+		}
+	}()
 
 	proc := divider.NewJobProcessor().
 		SetJobProvider(provider).
 		SetResultReporter(reporter).
 		SetWorkersCount(workers).
-		SetLogger(logger).
+		SetChunkSize(chunkSize).
+		SetWorkStealing(workStealing).
+		SetThresholds(thresholds).
+		SetMaxDuration(maxDuration).
+		SetShard(divider.Shard(shard)).
+		SetIDPolicy(divider.IDPolicy(idPolicy)).
+		SetCheckpoint(checkpointEvery, func(cp divider.Checkpoint) error {
+			return divider.SaveCheckpoint(checkpointPath, cp)
+		}).
+		SetLogger(jobLogger(logger)).
 		SetDivider(d)
+	if resume {
+		proc.SetResume(cp)
+	}
+	for _, s := range stages.stages {
+		proc.AddStage(s)
+	}
 
-	done := make(chan error, 1)
+	if len(deadLetterPath) != 0 {
+		dlFile, dlw, err := openDeadLetters(cp.DeadLetters)
+		if err != nil {
+			return fail(err)
+		}
+		pending = append(pending, dlFile)
+		for _, dec := range decoders {
+			dec.SetKeepRaw(true)
+		}
+		proc.SetDeadLetterWriter(dlw)
+	}
+
+	if len(metricsAddr) != 0 || len(metricsFilePath) != 0 {
+		m := metrics.New(methodName()).SetStats(proc.Stats)
+		proc.AddObserver(m)
+		if len(metricsAddr) != 0 {
+			stop, err := serveMetrics(metricsAddr, m)
+			if err != nil {
+				return fail(err)
+			}
+			defer stop()
+		}
+		if len(metricsFilePath) != 0 {
+			defer func() {
+				if err := m.WriteFile(metricsFilePath); err != nil {
+					logger.Error("failed to write metrics", "error", err)
+				}
+			}()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan runResult, 1)
 	go func() {
-		done <- proc.Start()
+		summary, err := proc.Run(ctx)
+		done <- runResult{summary, err}
 	}()
 
 	s := make(chan os.Signal, 1)
 	signal.Notify(s, os.Interrupt)
 	var ack bool
 
-loop:
 	for {
 		select {
 		case v := <-s:
 			if ack {
-				logger.Println("Stopping...")
+				logger.Info("stopping")
 				continue
 			}
 
-			logger.Printf("Signal received: %v. Stopping job processing...\n", v)
-			proc.Stop()
+			logger.Info("signal received, stopping job processing", "signal", v)
+			cancel()
 			ack = true
 
-		case err := <-done:
-			if err != nil {
-				logger.Fatal("Processing failed: ", err)
+		case res := <-done:
+			if len(summaryFilePath) != 0 {
+				if err := writeSummary(summaryFilePath, res.summary); err != nil {
+					logger.Error("failed to write summary", "error", err)
+				}
+			}
+
+			if errors.Is(res.err, divider.ErrBudgetExceeded) {
+				logger.Warn("run time budget spent, run with -resume to continue",
+					"next_id", res.summary.NextID, "checkpoint", checkpointPath)
+				logSummary(logger, res.summary)
+				return exitCode(res.err)
+			}
+
+			if res.err != nil {
+				logger.Error("processing failed", "error", res.err)
+				logSummary(logger, res.summary)
+				return exitCode(res.err)
+			}
+
+			var disabled map[string]error
+			if multi, ok := reporter.(*divider.MultiReporter); ok {
+				disabled = multi.Disabled()
+			}
+			finished = true
+			if !finishPending(logger, pending, !ack, disabled) {
+				return exitReporter
+			}
+
+			if ack {
+				logger.Info("stopped, run with -resume to continue", "checkpoint", checkpointPath)
+			} else if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+				logger.Warn("failed to remove checkpoint", "error", err)
+			}
+
+			stats := proc.Stats()
+
+			logger.Info("processing complete")
+			logSummary(logger, res.summary)
+			logger.Info("pool stats", "workers", stats.Workers,
+				"put_blocked", stats.PutBlocked, "deliver_blocked", stats.DeliverBlocked,
+				"latency_mean", stats.Latency.Mean(),
+				"latency_p50", stats.Latency.Quantile(0.5),
+				"latency_p99", stats.Latency.Quantile(0.99))
+			if limiter != nil {
+				logger.Info("rate limit", "throttled", limiter.Waited())
 			}
 
-			dur := time.Since(start)
-			rate := float64(proc.Processed()) / dur.Seconds()
+			return exitOK
+		}
+	}
+}
+
+// serveMetrics serves metrics over HTTP until stop is called.
+func serveMetrics(addr string, m *metrics.Metrics) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	return func() { srv.Close() }, nil
+}
 
-			logger.Println("Complete.")
-			logger.Println("Processed jobs:", proc.Processed())
-			logger.Printf("Time taken: %v, Avg. rate: %v\n", dur, uint64(rate))
+type runResult struct {
+	summary divider.Summary
+	err     error
+}
 
-			break loop
+func logSummary(logger *slog.Logger, s divider.Summary) {
+	logger.Info("summary", "total", s.Total, "valid", s.Valid,
+		"invalid_input", s.InvalidInput, "division_errors", s.DivErrors,
+		"duration", s.Duration, "rate", uint64(s.Throughput))
+	if len(s.Sources) > 1 {
+		for _, src := range s.Sources {
+			logger.Info("source summary", "source", src.Source, "total", src.Total,
+				"valid", src.Valid, "invalid_input", src.InvalidInput,
+				"division_errors", src.DivErrors)
 		}
 	}
+	for _, e := range s.Errors {
+		logger.Info("error sample", "job_id", e.ID, "kind", e.Kind, "error", e.Error)
+	}
+}
+
+func writeSummary(path string, s divider.Summary) error {
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, append(b, '\n'))
 }
 
 func closeSyncFile(f *os.File) {
@@ -148,17 +381,28 @@ func syncFile(f *os.File) {
 	}
 }
 
-func exitOn(err error) {
-	if err != nil {
-		fmt.Fprint(os.Stderr, err)
-		os.Exit(1)
-	}
+// fail reports the error of the run setup, such as a file which can not be
+// opened, and returns the exit code.
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, err)
+	return exitSetup
 }
 
 func exitWithUsage(msg ...interface{}) {
 	fmt.Fprintln(os.Stderr, msg...)
 	flag.PrintDefaults()
-	os.Exit(1)
+	os.Exit(exitUsage)
+}
+
+// methodName returns the name of the division method in use.
+func methodName() string {
+	switch {
+	case dontUseExt:
+		return "go"
+	case len(method) == 0:
+		return "syscall"
+	}
+	return method
 }
 
 func parseDivider(s string) div.Divider {
@@ -0,0 +1,38 @@
+//+build windows
+
+package main
+
+import (
+	"errors"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+)
+
+// Exit codes of divider.
+const (
+	exitOK             = 0
+	exitFailure        = 1
+	exitUsage          = 2
+	exitSetup          = 3
+	exitMalformedInput = 4
+	exitReporter       = 5
+	exitThreshold      = 6
+	exitBudget         = 7
+)
+
+// exitCode maps the error of the run to the exit code by its category.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, divider.ErrMalformedInput):
+		return exitMalformedInput
+	case errors.Is(err, divider.ErrReporter):
+		return exitReporter
+	case errors.Is(err, divider.ErrThresholdExceeded):
+		return exitThreshold
+	case errors.Is(err, divider.ErrBudgetExceeded):
+		return exitBudget
+	}
+	return exitFailure
+}
@@ -0,0 +1,120 @@
+//+build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
+	"github.com/b-2019-apt-test/divider/internal/divider/multiprov"
+)
+
+// inputFlags is a repeated flag of jobs file paths and glob patterns.
+type inputFlags []string
+
+func (f *inputFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *inputFlags) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+var (
+	inputs           inputFlags
+	concurrentInputs bool
+	sourceColumns    bool
+	idPolicy         idPolicyFlag
+	inputIDColumn    bool
+)
+
+var idPolicies = []string{"keep", "auto", "warn", "reject"}
+
+// idPolicyFlag is divider.IDPolicy named as in idPolicies.
+type idPolicyFlag divider.IDPolicy
+
+func (f *idPolicyFlag) String() string {
+	return idPolicies[*f]
+}
+
+func (f *idPolicyFlag) Set(s string) error {
+	for i, name := range idPolicies {
+		if s == name {
+			*f = idPolicyFlag(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown ID policy %q", s)
+}
+
+// expand resolves glob patterns to file paths in order of the flags. A
+// pattern without matches is an error, a path without meta characters is
+// kept to fail on opening. Files matched twice are read once.
+func (f inputFlags) expand() ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, pattern := range f {
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, `*?[`) {
+			var err error
+			if matches, err = filepath.Glob(pattern); err != nil {
+				return nil, fmt.Errorf("%s: %v", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no jobs files match %s", pattern)
+			}
+		}
+		for _, path := range matches {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// inputProvider is the provider of jobs of all the jobs files.
+type inputProvider interface {
+	divider.JobProvider
+	Close() error
+}
+
+// openInputs opens the jobs files and merges them into a single provider.
+// A resumed run has a single input which continues from the checkpoint.
+func openInputs(paths []string, cp divider.Checkpoint) ([]*os.File, inputProvider, []*jsonprov.JSONJobDecoder, error) {
+	var files []*os.File
+	var decoders []*jsonprov.JSONJobDecoder
+	var in []multiprov.Input
+	for _, path := range paths {
+		f, err := os.OpenFile(path, os.O_RDONLY, 0400)
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, nil, err
+		}
+		files = append(files, f)
+		dec, err := jsonprov.NewAt(f, cp.Offset)
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		decoders = append(decoders, dec)
+		in = append(in, multiprov.Input{Name: path, Provider: dec, Index: cp.NextID})
+	}
+
+	if concurrentInputs {
+		return files, multiprov.NewConcurrent(in...), decoders, nil
+	}
+	return files, multiprov.New(in...), decoders, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		closeFile(f)
+	}
+}
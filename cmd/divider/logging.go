@@ -0,0 +1,88 @@
+//+build windows
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/b-2019-apt-test/divider/pkg/logsample"
+)
+
+var (
+	logFilePath string
+	logFormat   string
+	logLevel    slog.Level
+	logSample   uint
+)
+
+// logFlags defines logging flags of fs.
+func logFlags(fs *flag.FlagSet) {
+	fs.StringVar(&logFilePath, "log", "", "log file path")
+	fs.StringVar(&logFormat, "log-format", "text", "log format: text, json")
+	fs.TextVar(&logLevel, "log-level", slog.LevelInfo, "min log level: debug, info, warn, error")
+	fs.UintVar(&logSample, "log-sample", 10, "max log records of the same kind per second about particular jobs at warn level and below, 0 disables sampling")
+}
+
+func checkLogFlags() error {
+	if logFormat != "text" && logFormat != "json" {
+		return fmt.Errorf("log format unknown: %s", logFormat)
+	}
+	return nil
+}
+
+// newLogger creates a logger writing to the log file or, if it is not
+// specified or can not be opened, to stderr. The returned func must be called
+// once logging is over, it reports records sampled out by jobLogger and
+// closes the file.
+func newLogger() (*slog.Logger, func()) {
+
+	var w io.Writer = os.Stderr
+	var logFile *os.File
+	if len(logFilePath) != 0 {
+		f, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			w, logFile = f, f
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var h slog.Handler
+	if logFormat == "json" {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+
+	logger := slog.New(h)
+
+	return logger, func() {
+		if sampler != nil && sampler.Dropped() > 0 {
+			logger.Info("log records sampled out", "count", sampler.Dropped())
+		}
+		if logFile != nil {
+			closeSyncFile(logFile)
+		}
+	}
+}
+
+// sampler samples the records of the logger returned by jobLogger.
+var sampler *logsample.Handler
+
+// jobLogger returns the logger for records of particular jobs. Unless
+// sampling is disabled, its warn and lower records are sampled. Summaries
+// and reports are logged with the logger itself, so no line of them is
+// lost.
+func jobLogger(logger *slog.Logger) *slog.Logger {
+	if logSample == 0 {
+		return logger
+	}
+	sampler = logsample.New(logger.Handler(), time.Second, logSample, 0).SetMaxLevel(slog.LevelWarn)
+	return slog.New(sampler)
+}
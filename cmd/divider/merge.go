@@ -0,0 +1,79 @@
+//+build windows
+
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/csvrep"
+)
+
+// shardFlag is divider.Shard given as i/n.
+type shardFlag divider.Shard
+
+func (f *shardFlag) String() string {
+	if f.Count == 0 {
+		return ""
+	}
+	return divider.Shard(*f).String()
+}
+
+func (f *shardFlag) Set(s string) error {
+	sh, err := divider.ParseShard(s)
+	f.Index, f.Count = sh.Index, sh.Count
+	return err
+}
+
+// merge combines CSV results of shards into one file ordered by job ID and
+// returns the exit code.
+func merge(args []string) int {
+
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	var in inputFlags
+	fs.Var(&in, "i", "path or glob pattern of the shard results files, repeatable")
+	output := fs.String("o", "divider.csv", "merged results file path")
+	logFlags(fs)
+	fs.Parse(args)
+
+	if len(in) == 0 || len(fs.Args()) != 0 || checkLogFlags() != nil {
+		fs.Usage()
+		return exitUsage
+	}
+
+	logger, closeLog := newLogger()
+	defer closeLog()
+
+	paths, err := in.expand()
+	if err != nil {
+		return fail(err)
+	}
+	var files []*os.File
+	defer func() { closeFiles(files) }()
+	var rs []io.Reader
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return fail(err)
+		}
+		files = append(files, f)
+		rs = append(rs, f)
+	}
+
+	writer, err := openPending(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fail(err)
+	}
+	if err = csvrep.Merge(writer, rs...); err != nil {
+		logger.Error("merge failed", "error", err)
+		finishPending(logger, []*pendingFile{writer}, false, nil)
+		return exitFailure
+	}
+	if !finishPending(logger, []*pendingFile{writer}, true, nil) {
+		return exitReporter
+	}
+	logger.Info("merge complete", "inputs", len(paths), "output", *output)
+	return exitOK
+}
@@ -0,0 +1,200 @@
+//+build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/csvrep"
+	"github.com/b-2019-apt-test/divider/internal/divider/deadletter"
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonrep"
+)
+
+// output is a results file given with -o flag as [optional:][format:]path.
+// Failure of an optional output disables it, the run goes on.
+type output struct {
+	format   string
+	path     string
+	optional bool
+}
+
+func (o output) String() string {
+	s := o.format + ":" + o.path
+	if o.optional {
+		s = "optional:" + s
+	}
+	return s
+}
+
+var formats = []string{"csv", "ndjson"}
+
+// outputFlags is a repeated flag of outputs.
+type outputFlags []output
+
+func (f *outputFlags) String() string {
+	s := make([]string, len(*f))
+	for i, o := range *f {
+		s[i] = o.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// Set parses the output. Prefixes are only taken for known words, so that
+// paths like C:\results.csv stay intact.
+func (f *outputFlags) Set(s string) error {
+	o := output{format: "csv", path: s}
+	if rest := strings.TrimPrefix(o.path, "optional:"); rest != o.path {
+		o.optional, o.path = true, rest
+	}
+	for _, format := range formats {
+		if rest := strings.TrimPrefix(o.path, format+":"); rest != o.path {
+			o.format, o.path = format, rest
+			break
+		}
+	}
+	if len(o.path) == 0 {
+		return errors.New("empty output path")
+	}
+	*f = append(*f, o)
+	return nil
+}
+
+var outputs outputFlags
+
+// openOutputs creates the partial results files or, if the run is resumed,
+// reopens them and drops the results written after the checkpoint. Several
+// outputs are teed with divider.MultiReporter.
+func openOutputs(cp divider.Checkpoint, logger *slog.Logger) ([]*pendingFile, divider.ResultReporter, error) {
+	sizes := cp.Outputs
+	if len(sizes) == 0 {
+		sizes = []int64{cp.Output}
+	}
+	if resume && len(sizes) != len(outputs) {
+		return nil, nil, fmt.Errorf("checkpoint has %d outputs, %d given", len(sizes), len(outputs))
+	}
+
+	var files []*pendingFile
+	var reporters []divider.ResultReporter
+	multi := divider.NewMultiReporter().SetLogger(logger)
+	for i, o := range outputs {
+		var size int64
+		if resume {
+			size = sizes[i]
+		}
+		f, r, err := openOutput(o, size)
+		if err != nil {
+			finishPending(logger, files, false, nil)
+			return nil, nil, err
+		}
+		files, reporters = append(files, f), append(reporters, r)
+		policy := divider.FailRun
+		if o.optional {
+			policy = divider.DisableOnError
+		}
+		multi.Add(o.path, r, policy)
+	}
+
+	if len(outputs) == 1 {
+		return files, reporters[0], nil
+	}
+	return files, multi, nil
+}
+
+// openOutput creates the partial results file or, if the run is resumed,
+// reopens it and truncates it to size.
+func openOutput(o output, size int64) (*pendingFile, divider.ResultReporter, error) {
+	if !resume {
+		f, err := openPending(o.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return nil, nil, err
+		}
+		if o.format == "ndjson" {
+			return f, jsonrep.New(f), nil
+		}
+		r, err := csvrep.New(f, csvOptions()...)
+		if err != nil {
+			f.finish(false)
+			return nil, nil, err
+		}
+		return f, r, nil
+	}
+
+	f, err := openPending(o.path, os.O_RDWR)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = truncate(f, size); err != nil {
+		f.finish(false)
+		return nil, nil, err
+	}
+	if o.format == "ndjson" {
+		return f, jsonrep.Resume(f, size), nil
+	}
+	return f, csvrep.Resume(f, size, csvOptions()...), nil
+}
+
+// openDeadLetters creates the partial dead-letter file or, if the run is
+// resumed, reopens it and drops the dead letters written after the
+// checkpoint.
+func openDeadLetters(size int64) (*pendingFile, *deadletter.Writer, error) {
+	if !resume {
+		f, err := openPending(deadLetterPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, deadletter.New(f), nil
+	}
+
+	// The file is only created if no dead letters were checkpointed.
+	flag := os.O_RDWR
+	if size == 0 {
+		flag |= os.O_CREATE
+	}
+	f, err := openPending(deadLetterPath, flag)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = truncate(f, size); err != nil {
+		f.finish(false)
+		return nil, nil, err
+	}
+	return f, deadletter.Resume(f, size), nil
+}
+
+// truncate drops the data written to the file after size and seeks to its
+// end. It fails if the file is shorter than size, so that a lost or cut
+// partial file is not padded with zeros.
+func truncate(f *pendingFile, size int64) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < size {
+		return fmt.Errorf("%s has %d bytes, checkpoint has %d", f.Name(), fi.Size(), size)
+	}
+	if err = f.Truncate(size); err != nil {
+		return err
+	}
+	_, err = f.Seek(0, io.SeekEnd)
+	return err
+}
+
+func csvOptions() []csvrep.Option {
+	var opts []csvrep.Option
+	if reasonColumn {
+		opts = append(opts, csvrep.ReasonColumn())
+	}
+	if sourceColumns {
+		opts = append(opts, csvrep.SourceColumns())
+	}
+	if inputIDColumn {
+		opts = append(opts, csvrep.InputIDColumn())
+	}
+	return opts
+}
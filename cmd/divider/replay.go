@@ -0,0 +1,108 @@
+//+build windows
+
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"runtime"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/csvrep"
+	"github.com/b-2019-apt-test/divider/internal/divider/deadletter"
+)
+
+// replay processes jobs from a dead-letter file again and returns the exit
+// code. Results keep job IDs of the original run.
+func replay(args []string) int {
+
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	input := fs.String("i", "", "path to the dead-letter file")
+	output := fs.String("o", "replay.csv", "results file path")
+	dlPath := fs.String("deadletter", "", "dead-letter file path for jobs failed again")
+	logFlags(fs)
+	wc := fs.Uint("w", uint(runtime.NumCPU()*1024), "workers count")
+	m := fs.String("m", "syscall", "division method: go, cgo, syscall")
+	fs.BoolVar(&reasonColumn, "reason", false, "add reason column to results file")
+	fs.Parse(args)
+
+	if len(*input) == 0 || len(fs.Args()) != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	d := parseDivider(*m)
+	if d == nil || checkLogFlags() != nil {
+		fs.Usage()
+		return exitUsage
+	}
+
+	logger, closeLog := newLogger()
+	defer closeLog()
+	logger = logger.With("method", *m)
+
+	reader, err := os.Open(*input)
+	if err != nil {
+		return fail(err)
+	}
+	defer closeFile(reader)
+	provider := deadletter.NewProvider(reader)
+
+	writer, err := openPending(*output, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fail(err)
+	}
+	pending := []*pendingFile{writer}
+	finished := false
+	defer func() {
+		if !finished {
+			finishPending(logger, pending, false, nil)
+		}
+	}()
+	reporter, err := csvrep.New(writer, csvOptions()...)
+	if err != nil {
+		return fail(err)
+	}
+
+	proc := divider.NewJobProcessor().
+		SetJobProvider(provider).
+		SetResultReporter(reporter).
+		SetWorkersCount(*wc).
+		SetLogger(jobLogger(logger)).
+		SetDivider(d)
+
+	if len(*dlPath) != 0 {
+		dlFile, err := openPending(*dlPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return fail(err)
+		}
+		pending = append(pending, dlFile)
+		proc.SetDeadLetterWriter(deadletter.New(dlFile))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	summary, err := proc.Run(ctx)
+	if err != nil {
+		logger.Error("replay failed", "error", err)
+		logSummary(logger, summary)
+		return exitCode(err)
+	}
+
+	// An interrupted replay can not be resumed, its output stays partial.
+	if ctx.Err() != nil {
+		logger.Info("replay stopped")
+		logSummary(logger, summary)
+		return exitOK
+	}
+
+	finished = true
+	if !finishPending(logger, pending, true, nil) {
+		return exitReporter
+	}
+	logger.Info("replay complete")
+	logSummary(logger, summary)
+	return exitOK
+}
@@ -0,0 +1,33 @@
+//+build windows
+
+package main
+
+import (
+	"strings"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/stage"
+)
+
+// stageFlags is a repeated flag of pre-processing stage specs, applied in
+// the order given.
+type stageFlags struct {
+	specs  []string
+	stages []divider.Stage
+}
+
+func (f *stageFlags) String() string {
+	return strings.Join(f.specs, ",")
+}
+
+func (f *stageFlags) Set(spec string) error {
+	s, err := stage.Parse(spec)
+	if err != nil {
+		return err
+	}
+	f.specs = append(f.specs, spec)
+	f.stages = append(f.stages, s)
+	return nil
+}
+
+var stages stageFlags
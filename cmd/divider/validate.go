@@ -0,0 +1,79 @@
+//+build windows
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"math"
+	"sort"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/validate"
+)
+
+// validateJobs checks the jobs files without dividing and returns the exit
+// code. Problems of particular jobs are only reported, errors which stop
+// reading of a file fail the validation.
+func validateJobs(args []string) int {
+
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	var in inputFlags
+	fs.Var(&in, "i", "path or glob pattern of the files with jobs, repeatable")
+	min := fs.Int("min", math.MinInt32, "min valid operand")
+	max := fs.Int("max", math.MaxInt32, "max valid operand")
+	samples := fs.Int("samples", validate.DefaultSamples, "count of samples of every problem")
+	reportPath := fs.String("report", "", "validation report JSON file path")
+	logFlags(fs)
+	fs.Parse(args)
+
+	if len(in) == 0 || len(fs.Args()) != 0 || *min > *max || checkLogFlags() != nil {
+		fs.Usage()
+		return exitUsage
+	}
+
+	logger, closeLog := newLogger()
+	defer closeLog()
+
+	paths, err := in.expand()
+	if err != nil {
+		return fail(err)
+	}
+	readers, provider, _, err := openInputs(paths, divider.Checkpoint{})
+	if err != nil {
+		return fail(err)
+	}
+	defer closeFiles(readers)
+
+	report, err := validate.New().SetRange(*min, *max).SetSamples(*samples).Run(provider)
+
+	logger.Info("validation report", "jobs", report.Jobs, "valid", report.Valid)
+	reasons := make([]divider.Reason, 0, len(report.Problems))
+	for reason := range report.Problems {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool { return reasons[i] < reasons[j] })
+	for _, reason := range reasons {
+		logger.Info("problem", "reason", reason, "count", report.Problems[reason])
+	}
+	for _, s := range report.Samples {
+		logger.Info("problem sample", "number", s.Number, "source", s.Source,
+			"index", s.Index, "reason", s.Reason, "error", s.Error)
+	}
+	if report.Fatal != nil {
+		logger.Error("validation failed", "error", report.Fatal.Error,
+			"line", report.Fatal.Line, "column", report.Fatal.Column)
+	}
+
+	if len(*reportPath) != 0 {
+		b, jerr := json.MarshalIndent(report, "", "\t")
+		if jerr == nil {
+			jerr = writeFile(*reportPath, b)
+		}
+		if jerr != nil {
+			logger.Error("failed to write report", "error", jerr)
+		}
+	}
+
+	return exitCode(err)
+}
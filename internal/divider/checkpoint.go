@@ -0,0 +1,110 @@
+package divider
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/b-2019-apt-test/divider/pkg/atomicfile"
+)
+
+// Checkpoint records how far a run got, so that it can be resumed without
+// duplicated or missed results. All the jobs before NextID are completed.
+type Checkpoint struct {
+	// Offset is the input offset of the job NextID as reported by
+	// Positioner.
+	Offset int64 `json:"offset"`
+	// NextID is the ID of the first job which is not completed.
+	NextID uint64 `json:"next_id"`
+	// Done lists IDs greater than NextID which are completed already.
+	Done []uint64 `json:"done,omitempty"`
+	// Output is the size of the reporter output as reported by Sizer.
+	Output int64 `json:"output"`
+	// Outputs are the sizes of the outputs of MultiSizer reporter.
+	Outputs []int64 `json:"outputs,omitempty"`
+	// DeadLetters is the size of the dead-letter output as reported by
+	// Sizer.
+	DeadLetters int64 `json:"dead_letters"`
+}
+
+// Positioner is implemented by job providers which can tell the input offset
+// where the next job starts.
+type Positioner interface {
+	Offset() int64
+}
+
+// Sizer is implemented by result reporters and dead-letter writers which
+// can tell the size of the output written so far.
+type Sizer interface {
+	Size() int64
+}
+
+// MultiSizer is implemented by result reporters with several outputs, like
+// MultiReporter.
+type MultiSizer interface {
+	Sizes() []int64
+}
+
+// LoadCheckpoint reads a checkpoint from the file.
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	var cp Checkpoint
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return cp, err
+	}
+	err = json.Unmarshal(b, &cp)
+	return cp, err
+}
+
+// SaveCheckpoint writes the checkpoint to the file. The file is replaced
+// atomically, so it always holds a complete checkpoint.
+func SaveCheckpoint(path string, cp Checkpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(path, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// progress tracks the contiguously completed jobs. It is only accessed by
+// the result reporting goroutine until the run is over.
+type progress struct {
+	next   uint64
+	offset int64
+	done   map[uint64]int64
+}
+
+func newProgress(next uint64, offset int64) progress {
+	return progress{next: next, offset: offset, done: make(map[uint64]int64)}
+}
+
+// complete marks the job completed. End is the input offset where the job
+// ends, which is the offset of the next job.
+func (pr *progress) complete(id uint64, end int64) {
+	if id != pr.next {
+		pr.done[id] = end
+		return
+	}
+	pr.next, pr.offset = id+1, end
+	for {
+		end, ok := pr.done[pr.next]
+		if !ok {
+			return
+		}
+		delete(pr.done, pr.next)
+		pr.next, pr.offset = pr.next+1, end
+	}
+}
+
+func (pr *progress) checkpoint() Checkpoint {
+	cp := Checkpoint{Offset: pr.offset, NextID: pr.next}
+	for id := range pr.done {
+		cp.Done = append(cp.Done, id)
+	}
+	sort.Slice(cp.Done, func(i, j int) bool { return cp.Done[i] < cp.Done[j] })
+	return cp
+}
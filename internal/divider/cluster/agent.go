@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/pkg/div"
+)
+
+// Agent divides jobs leased by Coordinator.
+type Agent struct {
+	d   div.Divider
+	log *slog.Logger
+}
+
+// NewAgent creates a new Agent which divides with d.
+func NewAgent(d div.Divider) *Agent {
+	return &Agent{d: d, log: slog.New(slog.DiscardHandler)}
+}
+
+// SetLogger sets the logger of the agent.
+func (a *Agent) SetLogger(logger *slog.Logger) *Agent {
+	a.log = logger
+	return a
+}
+
+// Run processes batches leased on the connection until the coordinator tells
+// that all the jobs are done or ctx is canceled. The connection is not
+// closed.
+func (a *Agent) Run(ctx context.Context, nc net.Conn) error {
+	stop := context.AfterFunc(ctx, func() { nc.SetDeadline(time.Now()) })
+	defer stop()
+
+	c := newConn(nc)
+	for {
+		var m message
+		err := c.send(&message{Type: msgLease})
+		if err == nil {
+			err = c.recv(&m)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		switch m.Type {
+		case msgDone:
+			return nil
+		case msgWait:
+			select {
+			case <-time.After(time.Duration(m.RetryMS) * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case msgBatch:
+			a.log.Debug("batch leased", "lease", m.Lease, "jobs", len(m.Jobs))
+			res := &message{Type: msgResults, Lease: m.Lease, Results: make([]result, len(m.Jobs))}
+			for i, j := range m.Jobs {
+				res.Results[i] = a.divide(j)
+			}
+			if err = c.send(res); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected message %q", m.Type)
+		}
+	}
+}
+
+func (a *Agent) divide(j job) (r result) {
+	r.ID = j.ID
+	defer func() {
+		if v := recover(); v != nil {
+			r = result{ID: j.ID, Reason: divider.ReasonPanic, Message: fmt.Sprint("panic: ", v)}
+		}
+	}()
+	v, err := a.d.Div(j.Arg1, j.Arg2)
+	if err != nil {
+		r.Reason, r.Message = divider.DivisionReason(err), err.Error()
+		return
+	}
+	r.Value, r.Valid = v, true
+	return
+}
@@ -0,0 +1,77 @@
+// Package cluster distributes jobs of a JobProvider among agent processes
+// over TCP. Coordinator reads the jobs and leases them to agents in batches,
+// Agent divides the jobs of a batch and sends the results back. Batches of
+// agents which disconnect or do not complete them in time are leased again,
+// so every job is reported once.
+//
+// Agents and the coordinator exchange JSON messages, one per line. An agent
+// sends "lease" to get a batch and "results" with the results of the batch.
+// The coordinator answers "lease" with "batch", with "wait" if all the jobs
+// read are leased to other agents, or with "done" once all the results are
+// reported.
+package cluster
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+)
+
+// Message types.
+const (
+	msgLease   = "lease"
+	msgResults = "results"
+	msgBatch   = "batch"
+	msgWait    = "wait"
+	msgDone    = "done"
+)
+
+type message struct {
+	Type    string   `json:"type"`
+	Lease   uint64   `json:"lease,omitempty"`
+	Jobs    []job    `json:"jobs,omitempty"`
+	Results []result `json:"results,omitempty"`
+	// RetryMS tells an agent how long to wait before the next lease.
+	RetryMS int64 `json:"retry_ms,omitempty"`
+}
+
+type job struct {
+	ID   uint64 `json:"id"`
+	Arg1 int    `json:"arg1"`
+	Arg2 int    `json:"arg2"`
+}
+
+type result struct {
+	ID      uint64         `json:"id"`
+	Value   int            `json:"value"`
+	Valid   bool           `json:"valid"`
+	Reason  divider.Reason `json:"reason,omitempty"`
+	Message string         `json:"message,omitempty"`
+}
+
+// conn sends and receives messages on a connection.
+type conn struct {
+	net.Conn
+	dec *json.Decoder
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newConn(c net.Conn) *conn {
+	w := bufio.NewWriter(c)
+	return &conn{Conn: c, dec: json.NewDecoder(bufio.NewReader(c)), w: w, enc: json.NewEncoder(w)}
+}
+
+func (c *conn) send(m *message) error {
+	if err := c.enc.Encode(m); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+func (c *conn) recv(m *message) error {
+	*m = message{}
+	return c.dec.Decode(m)
+}
@@ -0,0 +1,217 @@
+package cluster_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/cluster"
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
+	"github.com/b-2019-apt-test/divider/internal/divider/mocks"
+)
+
+// jobs returns n jobs, every tenth of them divides by zero.
+func jobs(t *testing.T, n int) divider.JobProvider {
+	var b strings.Builder
+	b.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"arg1": %d, "arg2": %d}`, i*2, i%10)
+	}
+	b.WriteString(`, {"arg1": 1}]`)
+	prov, err := jsonprov.New(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prov
+}
+
+type coordinator struct {
+	*cluster.Coordinator
+	addr    string
+	summary divider.Summary
+	err     error
+	done    chan struct{}
+}
+
+func serve(t *testing.T, c *cluster.Coordinator) *coordinator {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &coordinator{Coordinator: c, addr: ln.Addr().String(), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		s.summary, s.err = c.Serve(context.Background(), ln)
+	}()
+	return s
+}
+
+func (s *coordinator) wait(t *testing.T) {
+	select {
+	case <-s.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("coordinator did not finish")
+	}
+}
+
+func agents(t *testing.T, addr string, n int) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			nc, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer nc.Close()
+			if err = cluster.NewAgent(mocks.FakeDivider).Run(context.Background(), nc); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	return &wg
+}
+
+// lease takes a batch as an agent and returns the connection and the lease.
+func lease(t *testing.T, addr string) (net.Conn, uint64) {
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(nc, `{"type": "lease"}`)
+	var m struct {
+		Type  string
+		Lease uint64
+	}
+	if err = json.NewDecoder(bufio.NewReader(nc)).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != "batch" {
+		t.Fatalf("expected batch, got %q", m.Type)
+	}
+	return nc, m.Lease
+}
+
+func validate(t *testing.T, results []*divider.JobResult, n int) {
+	if len(results) != n+1 {
+		t.Fatalf("expected %d results, got %d", n+1, len(results))
+	}
+	seen := make(map[uint64]bool)
+	for _, r := range results {
+		if seen[r.ID] {
+			t.Fatalf("job %d reported twice", r.ID)
+		}
+		seen[r.ID] = true
+		switch {
+		case r.ID == uint64(n):
+			if r.Valid || r.Reason != divider.ReasonArg2Missing {
+				t.Errorf("expected job %d rejected, got %+v", r.ID, r)
+			}
+		case r.ID%10 == 0:
+			if r.Valid || r.Reason != divider.ReasonDivZero {
+				t.Errorf("expected job %d failed, got %+v", r.ID, r)
+			}
+		case !r.Valid || r.Value != int(r.ID*2)/int(r.ID%10):
+			t.Errorf("unexpected result of job %d: %+v", r.ID, r)
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+
+	reporter := mocks.NewFakeResultReporter()
+	s := serve(t, cluster.NewCoordinator(jobs(t, 1000), reporter).SetBatchSize(7))
+	agents(t, s.addr, 4).Wait()
+	s.wait(t)
+
+	if s.err != nil {
+		t.Fatal(s.err)
+	}
+	validate(t, reporter.Results(), 1000)
+	if s.summary.Total != 1001 || s.summary.Valid != 900 ||
+		s.summary.DivErrors != 100 || s.summary.InvalidInput != 1 {
+		t.Errorf("unexpected summary: %+v", s.summary)
+	}
+}
+
+func TestDeadAgent(t *testing.T) {
+
+	reporter := mocks.NewFakeResultReporter()
+	s := serve(t, cluster.NewCoordinator(jobs(t, 100), reporter).SetBatchSize(10))
+
+	nc, _ := lease(t, s.addr)
+	nc.Close()
+	agents(t, s.addr, 2).Wait()
+	s.wait(t)
+
+	if s.err != nil {
+		t.Fatal(s.err)
+	}
+	validate(t, reporter.Results(), 100)
+}
+
+func TestLeaseTimeout(t *testing.T) {
+
+	reporter := mocks.NewFakeResultReporter()
+	s := serve(t, cluster.NewCoordinator(jobs(t, 100), reporter).
+		SetBatchSize(10).SetLeaseTimeout(50*time.Millisecond))
+
+	nc, id := lease(t, s.addr)
+	agents(t, s.addr, 2).Wait()
+
+	// Late results of the hung agent are dropped.
+	fmt.Fprintf(nc, `{"type": "results", "lease": %d, "results": []}`+"\n", id)
+	nc.Close()
+	s.wait(t)
+
+	if s.err != nil {
+		t.Fatal(s.err)
+	}
+	validate(t, reporter.Results(), 100)
+}
+
+func TestReporterError(t *testing.T) {
+
+	reporter := mocks.NewFakeResultReporter()
+	reporter.FailOn(5).FailFn(mocks.TerminalErrorFailFn)
+	s := serve(t, cluster.NewCoordinator(jobs(t, 100), reporter).SetBatchSize(10))
+	agents(t, s.addr, 2).Wait()
+	s.wait(t)
+
+	if !errors.Is(s.err, divider.ErrReporter) {
+		t.Fatalf("expected reporter error, got %v", s.err)
+	}
+	if s.summary.Total != 5 {
+		t.Errorf("expected 5 results, got %d", s.summary.Total)
+	}
+}
+
+func TestSettersDefaults(t *testing.T) {
+
+	// Invalid settings must not break Serve.
+	for _, timeout := range []time.Duration{0, time.Nanosecond} {
+		reporter := mocks.NewFakeResultReporter()
+		s := serve(t, cluster.NewCoordinator(jobs(t, 10), reporter).
+			SetBatchSize(0).SetLeaseTimeout(timeout))
+		agents(t, s.addr, 1).Wait()
+		s.wait(t)
+
+		if s.err != nil {
+			t.Fatal(s.err)
+		}
+		validate(t, reporter.Results(), 10)
+	}
+}
@@ -0,0 +1,428 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+)
+
+const (
+	// DefaultBatchSize is the default count of jobs in a batch.
+	DefaultBatchSize = 256
+	// DefaultLeaseTimeout is the default time an agent has to complete a
+	// batch before it is leased again.
+	DefaultLeaseTimeout = 30 * time.Second
+
+	retryInterval = 100 * time.Millisecond
+	// minExpireInterval is the min interval of lease expiration checks.
+	minExpireInterval = time.Millisecond
+	// gracePeriod is the time agents have to get "done" before their
+	// connections are closed.
+	gracePeriod = time.Second
+)
+
+// Coordinator reads jobs of a JobProvider and leases them to agents. Jobs
+// rejected by the provider are reported by the coordinator, results come in
+// order of completion of batches. Stages, ID policies, checkpoints and
+// dead letters of JobProcessor are not supported.
+type Coordinator struct {
+	prov         divider.JobProvider
+	rep          divider.ResultReporter
+	batchSize    int
+	leaseTimeout time.Duration
+	log          *slog.Logger
+
+	mu        sync.Mutex
+	nextID    uint64
+	nextLease uint64
+	// leases are the batches which are not completed, queue are the IDs of
+	// the ones to lease again.
+	leases map[uint64]*lease
+	queue  []uint64
+	eof    bool
+	err    error
+	sum    divider.Summary
+	done   chan struct{}
+	conns  map[net.Conn]bool
+}
+
+type lease struct {
+	id   uint64
+	jobs []job
+	// results have IDs, sources and input IDs of the jobs.
+	results  []divider.JobResult
+	holder   net.Conn
+	deadline time.Time
+}
+
+// NewCoordinator creates a new Coordinator.
+func NewCoordinator(prov divider.JobProvider, rep divider.ResultReporter) *Coordinator {
+	return &Coordinator{
+		prov:         prov,
+		rep:          rep,
+		batchSize:    DefaultBatchSize,
+		leaseTimeout: DefaultLeaseTimeout,
+		log:          slog.New(slog.DiscardHandler),
+		leases:       make(map[uint64]*lease),
+		done:         make(chan struct{}),
+		conns:        make(map[net.Conn]bool),
+	}
+}
+
+// SetBatchSize sets the count of jobs in a batch. Non-positive n sets
+// DefaultBatchSize.
+func (c *Coordinator) SetBatchSize(n int) *Coordinator {
+	if n <= 0 {
+		n = DefaultBatchSize
+	}
+	c.batchSize = n
+	return c
+}
+
+// SetLeaseTimeout sets the time an agent has to complete a batch.
+// Non-positive d sets DefaultLeaseTimeout.
+func (c *Coordinator) SetLeaseTimeout(d time.Duration) *Coordinator {
+	if d <= 0 {
+		d = DefaultLeaseTimeout
+	}
+	c.leaseTimeout = d
+	return c
+}
+
+// SetLogger sets the logger of the coordinator.
+func (c *Coordinator) SetLogger(logger *slog.Logger) *Coordinator {
+	c.log = logger
+	return c
+}
+
+// Serve accepts agents on the listener until all the jobs are reported,
+// processing fails or ctx is canceled. Then it closes the listener and the
+// connections of agents. If the reporter implements divider.Flusher, it is
+// flushed after the last result. Terminal errors of the provider and errors
+// of the reporter stop processing the same way they stop JobProcessor.
+func (c *Coordinator) Serve(ctx context.Context, ln net.Listener) (divider.Summary, error) {
+	start := time.Now()
+	stop := context.AfterFunc(ctx, func() { c.fail(ctx.Err()) })
+	defer stop()
+
+	var wg sync.WaitGroup
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				c.fail(err)
+				return
+			}
+			if !c.track(nc, &wg) {
+				nc.Close()
+				return
+			}
+			go func() {
+				defer wg.Done()
+				c.serveConn(nc)
+			}()
+		}
+	}()
+	go c.expire()
+
+	<-c.done
+	ln.Close()
+
+	handled := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(gracePeriod):
+		c.mu.Lock()
+		for nc := range c.conns {
+			nc.Close()
+		}
+		c.mu.Unlock()
+		<-handled
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f, ok := c.rep.(divider.Flusher); ok && c.err == nil {
+		if err := f.Flush(); err != nil {
+			c.err = &divider.ReporterError{Err: err}
+		}
+	}
+	c.sum.Duration = time.Since(start)
+	if s := c.sum.Duration.Seconds(); s > 0 {
+		c.sum.Throughput = float64(c.sum.Total) / s
+	}
+	return c.sum, c.err
+}
+
+// track adds the connection to the ones closed by Serve and to the ones
+// Serve waits for. It returns false if Serve is done.
+func (c *Coordinator) track(nc net.Conn, wg *sync.WaitGroup) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finished() {
+		return false
+	}
+	// done is closed under mu, so Add comes before Serve waits.
+	wg.Add(1)
+	c.conns[nc] = true
+	return true
+}
+
+func (c *Coordinator) serveConn(nc net.Conn) {
+	log := c.log.With("agent", nc.RemoteAddr().String())
+	log.Info("agent connected")
+	defer func() {
+		nc.Close()
+		c.release(nc, log)
+	}()
+
+	cn := newConn(nc)
+	for {
+		var m message
+		if err := cn.recv(&m); err != nil {
+			if !errors.Is(err, io.EOF) && !c.isDone() {
+				log.Warn("agent lost", "error", err)
+			}
+			return
+		}
+
+		var resp *message
+		switch m.Type {
+		case msgLease:
+			resp = c.lease(nc)
+		case msgResults:
+			if err := c.complete(&m, log); err != nil {
+				log.Warn("bad results", "lease", m.Lease, "error", err)
+				return
+			}
+			continue
+		default:
+			log.Warn("unexpected message", "type", m.Type)
+			return
+		}
+
+		if err := cn.send(resp); err != nil {
+			log.Warn("agent lost", "error", err)
+			return
+		}
+		if resp.Type == msgDone {
+			log.Info("agent done")
+			return
+		}
+	}
+}
+
+// lease leases the next batch to the holder.
+func (c *Coordinator) lease(holder net.Conn) *message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l := c.pop()
+	if l == nil {
+		l = c.read()
+	}
+	if l == nil {
+		if c.finished() {
+			return &message{Type: msgDone}
+		}
+		return &message{Type: msgWait, RetryMS: retryInterval.Milliseconds()}
+	}
+	l.holder, l.deadline = holder, time.Now().Add(c.leaseTimeout)
+	return &message{Type: msgBatch, Lease: l.id, Jobs: l.jobs}
+}
+
+// pop returns the first queued batch which is not completed.
+func (c *Coordinator) pop() *lease {
+	for len(c.queue) > 0 {
+		l := c.leases[c.queue[0]]
+		c.queue = c.queue[1:]
+		if l != nil {
+			return l
+		}
+	}
+	return nil
+}
+
+// read reads the next batch. Jobs rejected by the provider are reported
+// right away.
+func (c *Coordinator) read() *lease {
+	if c.eof || c.err != nil {
+		return nil
+	}
+	l := &lease{id: c.nextLease}
+	for len(l.jobs) < c.batchSize {
+		if !c.prov.More() {
+			c.eof = true
+			break
+		}
+		var j divider.Job
+		err := c.prov.Next(&j)
+		if err != nil {
+			var nterr *divider.NonTerminalError
+			if !errors.As(err, &nterr) {
+				c.setErr(err)
+				return nil
+			}
+		}
+		res := divider.JobResult{ID: c.nextID, Source: j.Source, Index: j.Index, InputID: j.InputID}
+		c.nextID++
+		if err != nil {
+			res.Reason, res.Message = divider.InputReason(err), err.Error()
+			if !c.report(&res) {
+				return nil
+			}
+			continue
+		}
+		l.jobs = append(l.jobs, job{ID: res.ID, Arg1: j.Arg1, Arg2: j.Arg2})
+		l.results = append(l.results, res)
+	}
+	if len(l.jobs) == 0 {
+		c.check()
+		return nil
+	}
+	c.nextLease++
+	c.leases[l.id] = l
+	return l
+}
+
+// complete reports the results of the batch. Results of batches completed
+// by another agent are dropped.
+func (c *Coordinator) complete(m *message, log *slog.Logger) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l := c.leases[m.Lease]
+	if l == nil {
+		log.Debug("results dropped", "lease", m.Lease)
+		return nil
+	}
+	if len(m.Results) != len(l.jobs) {
+		return fmt.Errorf("%d results for %d jobs", len(m.Results), len(l.jobs))
+	}
+	for i, r := range m.Results {
+		if r.ID != l.jobs[i].ID {
+			return fmt.Errorf("result of job %d instead of %d", r.ID, l.jobs[i].ID)
+		}
+	}
+
+	delete(c.leases, l.id)
+	for i, r := range m.Results {
+		res := l.results[i]
+		res.Value, res.Valid, res.Reason, res.Message = r.Value, r.Valid, r.Reason, r.Message
+		if !c.report(&res) {
+			return nil
+		}
+	}
+	c.check()
+	return nil
+}
+
+// report reports the result and counts it. It returns false if the
+// reporter failed.
+func (c *Coordinator) report(res *divider.JobResult) bool {
+	if c.err != nil {
+		return false
+	}
+	if err := c.rep.Report(res); err != nil {
+		c.setErr(&divider.ReporterError{Err: err})
+		return false
+	}
+	c.sum.Total++
+	switch {
+	case res.Valid:
+		c.sum.Valid++
+	case res.Reason == divider.ReasonDivZero || res.Reason == divider.ReasonDivFailed ||
+		res.Reason == divider.ReasonPanic || res.Reason == divider.ReasonTimeout:
+		c.sum.DivErrors++
+	default:
+		c.sum.InvalidInput++
+	}
+	return true
+}
+
+// release queues the batches of the holder to lease them again.
+func (c *Coordinator) release(holder net.Conn, log *slog.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.conns, holder)
+	for _, l := range c.leases {
+		if l.holder == holder {
+			log.Warn("batch released", "lease", l.id)
+			c.requeue(l)
+		}
+	}
+}
+
+// expire queues the batches whose lease timed out until Serve is done.
+func (c *Coordinator) expire() {
+	ticker := time.NewTicker(max(c.leaseTimeout/4, minExpireInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			for _, l := range c.leases {
+				if l.holder != nil && now.After(l.deadline) {
+					c.log.Warn("lease timed out", "lease", l.id,
+						"agent", l.holder.RemoteAddr().String())
+					c.requeue(l)
+				}
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+func (c *Coordinator) requeue(l *lease) {
+	l.holder = nil
+	c.queue = append(c.queue, l.id)
+}
+
+func (c *Coordinator) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.finished() {
+		c.setErr(err)
+	}
+}
+
+func (c *Coordinator) setErr(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+	c.check()
+}
+
+// finished reports whether all the jobs are reported or processing failed.
+func (c *Coordinator) finished() bool {
+	return c.err != nil || c.eof && len(c.leases) == 0
+}
+
+// check closes done once processing is finished.
+func (c *Coordinator) check() {
+	if c.finished() && !c.isDone() {
+		close(c.done)
+	}
+}
+
+func (c *Coordinator) isDone() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -3,6 +3,7 @@ package csvrep
 import (
 	"io"
 	"strconv"
+	"strings"
 
 	"github.com/b-2019-apt-test/divider/internal/divider"
 )
@@ -10,22 +11,122 @@ import (
 // CSVResultReporter produces CSV-formatted output of processing results and
 // writes it with provided Writer.
 type CSVResultReporter struct {
-	w io.Writer
+	w      io.Writer
+	buf    []byte
+	size   int64
+	reason bool
+	source bool
+	id     bool
 }
 
-// New creates a new CSVResultReporter.
-func New(w io.Writer) (*CSVResultReporter, error) {
-	r := &CSVResultReporter{w}
-	if _, err := r.w.Write([]byte("id,value,valid\n")); err != nil {
+// Option configures a CSVResultReporter.
+type Option func(*CSVResultReporter)
+
+// ReasonColumn adds the fourth column with the reason code of invalid
+// results. By default only id, value and valid columns are written.
+func ReasonColumn() Option {
+	return func(r *CSVResultReporter) {
+		r.reason = true
+	}
+}
+
+// SourceColumns adds source and index columns with the name of the input
+// file of the job and the number of the job in it. They follow the reason
+// column, if any.
+func SourceColumns() Option {
+	return func(r *CSVResultReporter) {
+		r.source = true
+	}
+}
+
+// InputIDColumn adds input_id column with the ID of the job given in the
+// input or the sequential ID if there is none. It is the last column.
+func InputIDColumn() Option {
+	return func(r *CSVResultReporter) {
+		r.id = true
+	}
+}
+
+// New creates a new CSVResultReporter. The header is written immediately.
+func New(w io.Writer, opts ...Option) (*CSVResultReporter, error) {
+	r := newReporter(w, opts)
+	header := "id,value,valid"
+	if r.reason {
+		header += ",reason"
+	}
+	if r.source {
+		header += ",source,index"
+	}
+	if r.id {
+		header += ",input_id"
+	}
+	n, err := io.WriteString(r.w, header+"\n")
+	if err != nil {
 		return nil, err
 	}
+	r.size = int64(n)
 	return r, nil
 }
 
+// Resume creates a new CSVResultReporter which appends results to the output
+// of size bytes written previously by another CSVResultReporter. The header
+// is not written.
+func Resume(w io.Writer, size int64, opts ...Option) *CSVResultReporter {
+	r := newReporter(w, opts)
+	r.size = size
+	return r
+}
+
+func newReporter(w io.Writer, opts []Option) *CSVResultReporter {
+	r := &CSVResultReporter{w: w}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
 // Report writes a CSV-formatted line for the job result.
 func (r *CSVResultReporter) Report(result *divider.JobResult) error {
-	_, err := r.w.Write([]byte((strconv.FormatUint(result.ID, 10) + "," +
-		strconv.FormatInt(int64(result.Value), 10) + "," +
-		strconv.FormatBool(result.Valid) + "\n")))
+	r.buf = strconv.AppendUint(r.buf[:0], result.ID, 10)
+	r.buf = append(r.buf, ',')
+	r.buf = strconv.AppendInt(r.buf, int64(result.Value), 10)
+	r.buf = append(r.buf, ',')
+	r.buf = strconv.AppendBool(r.buf, result.Valid)
+	if r.reason {
+		r.buf = append(r.buf, ',')
+		r.buf = append(r.buf, result.Reason...)
+	}
+	if r.source {
+		r.buf = append(r.buf, ',')
+		r.buf = appendField(r.buf, result.Source)
+		r.buf = append(r.buf, ',')
+		r.buf = strconv.AppendUint(r.buf, result.Index, 10)
+	}
+	if r.id {
+		r.buf = append(r.buf, ',')
+		if len(result.InputID) != 0 {
+			r.buf = appendField(r.buf, result.InputID)
+		} else {
+			r.buf = strconv.AppendUint(r.buf, result.ID, 10)
+		}
+	}
+	r.buf = append(r.buf, '\n')
+	n, err := r.w.Write(r.buf)
+	r.size += int64(n)
 	return err
 }
+
+// appendField appends the field quoted if needed.
+func appendField(buf []byte, s string) []byte {
+	if !strings.ContainsAny(s, ",\"\r\n") {
+		return append(buf, s...)
+	}
+	buf = append(buf, '"')
+	buf = append(buf, strings.ReplaceAll(s, `"`, `""`)...)
+	return append(buf, '"')
+}
+
+// Size returns the number of bytes written.
+func (r *CSVResultReporter) Size() int64 {
+	return r.size
+}
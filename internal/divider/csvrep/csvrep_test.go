@@ -3,9 +3,11 @@ package csvrep_test
 import (
 	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/b-2019-apt-test/divider/internal/divider"
@@ -91,3 +93,87 @@ func results(r io.Reader) ([]*divider.JobResult, error) {
 		})
 	}
 }
+
+func TestReasonColumn(t *testing.T) {
+
+	var buf bytes.Buffer
+	reporter, _ := csvrep.New(&buf, csvrep.ReasonColumn())
+	proc := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(mocks.ProcessingError.Jobs)).
+		SetResultReporter(reporter)
+
+	if err := proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "id,value,valid,reason\n0,1,true,\n1,0,false,div_zero\n2,1,true,\n"
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestReportErr(t *testing.T) {
+	reporter, err := csvrep.New(mocks.NewBrokenWriter(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(mocks.AllValid.Jobs)).
+		SetResultReporter(reporter)
+
+	var rerr *divider.ReporterError
+	if err := proc.Start(); !errors.As(err, &rerr) || rerr.Err != mocks.ErrBrokenWriter {
+		t.Fatalf("expected writer error, got: %v", err)
+	}
+}
+
+func TestOptionalColumns(t *testing.T) {
+
+	var buf bytes.Buffer
+	reporter, _ := csvrep.New(&buf, csvrep.ReasonColumn(), csvrep.SourceColumns(), csvrep.InputIDColumn())
+	reporter.Report(&divider.JobResult{ID: 0, Value: 2, Valid: true, Source: "a.json", Index: 0, InputID: "x-1"})
+	reporter.Report(&divider.JobResult{ID: 1, Reason: divider.ReasonDivZero, Source: `b,"c".json`, Index: 7})
+
+	expected := "id,value,valid,reason,source,index,input_id\n0,2,true,,a.json,0,x-1\n1,0,false,div_zero,\"b,\"\"c\"\".json\",7,1\n"
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestMerge(t *testing.T) {
+
+	shards := []string{
+		"id,value,valid\n3,1,true\n0,2,true\n",
+		"id,value,valid\n1,0,false\n4,5,true\n",
+		"id,value,valid\n",
+		"id,value,valid\n2,7,true\n",
+	}
+	var rs []io.Reader
+	for _, s := range shards {
+		rs = append(rs, strings.NewReader(s))
+	}
+
+	var buf bytes.Buffer
+	if err := csvrep.Merge(&buf, rs...); err != nil {
+		t.Fatal(err)
+	}
+	expected := "id,value,valid\n0,2,true\n1,0,false\n2,7,true\n3,1,true\n4,5,true\n"
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	bad := [][]string{
+		{"id,value,valid\n1,0,true\n", "id,value,valid\n1,0,true\n"},
+		{"id,value,valid\n1,0,true\n", "id,value,valid,reason\n2,0,true,\n"},
+		{"id,value,valid\nx,0,true\n"},
+	}
+	for _, inputs := range bad {
+		rs = rs[:0]
+		for _, s := range inputs {
+			rs = append(rs, strings.NewReader(s))
+		}
+		if err := csvrep.Merge(io.Discard, rs...); err == nil {
+			t.Fatalf("merge of %q must fail", inputs)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package csvrep
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+type row struct {
+	id     uint64
+	fields []string
+}
+
+// Merge combines CSV outputs of shards of a run into one ordered by ID. The
+// outputs must have the same columns. Rows of all the outputs are kept in
+// memory as results of a run are not ordered. An ID found twice means the
+// shards overlap and is an error.
+func Merge(w io.Writer, rs ...io.Reader) error {
+	var header []string
+	var rows []row
+
+	for i, r := range rs {
+		csvr := csv.NewReader(r)
+		h, err := csvr.Read()
+		if err != nil {
+			return fmt.Errorf("input %d: reading header: %v", i, err)
+		}
+		if header == nil {
+			header = h
+		} else if !equal(header, h) {
+			return fmt.Errorf("input %d: columns %v differ from %v", i, h, header)
+		}
+		for {
+			fields, err := csvr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("input %d: %v", i, err)
+			}
+			id, err := strconv.ParseUint(fields[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("input %d: invalid id: %v", i, err)
+			}
+			rows = append(rows, row{id, fields})
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].id < rows[j].id })
+
+	csvw := csv.NewWriter(w)
+	if header != nil {
+		if err := csvw.Write(header); err != nil {
+			return err
+		}
+	}
+	for i, r := range rows {
+		if i > 0 && rows[i-1].id == r.id {
+			return fmt.Errorf("duplicate id %d", r.id)
+		}
+		if err := csvw.Write(r.fields); err != nil {
+			return err
+		}
+	}
+	csvw.Flush()
+	return csvw.Error()
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
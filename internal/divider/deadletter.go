@@ -0,0 +1,57 @@
+package divider
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Stages of job processing at which a job may fail.
+const (
+	StageParse      = "parse"
+	StageValidation = "validation"
+	StagePreprocess = "preprocess"
+	StageDivision   = "division"
+)
+
+// DeadLetter describes a job which failed to be processed. Input is the job
+// as it was read, so the job can be inspected and processed again.
+type DeadLetter struct {
+	ID    uint64          `json:"id"`
+	Input json.RawMessage `json:"input,omitempty"`
+	Stage string          `json:"stage"`
+	Error string          `json:"error"`
+}
+
+// DeadLetterWriter records jobs failed to be processed. It is flushed and
+// closed by JobProcessor the same way as ResultReporter.
+type DeadLetterWriter interface {
+	Write(*DeadLetter) error
+}
+
+func newDeadLetter(task *workerTask) *DeadLetter {
+	dl := &DeadLetter{ID: task.result.ID, Input: task.job.Raw, Stage: stageOf(task)}
+	if task.err != nil {
+		dl.Error = task.err.Error()
+	}
+	if dl.Input == nil && task.job.Valid {
+		dl.Input, _ = json.Marshal(struct {
+			Arg1 int `json:"arg1"`
+			Arg2 int `json:"arg2"`
+		}{task.job.Arg1, task.job.Arg2})
+	}
+	return dl
+}
+
+// stageOf returns the stage at which the job failed.
+func stageOf(task *workerTask) string {
+	var serr *StageError
+	switch {
+	case errors.As(task.err, &serr):
+		return StagePreprocess
+	case task.job.Valid:
+		return StageDivision
+	case errors.Is(task.err, ErrArg1Missing) || errors.Is(task.err, ErrArg2Missing):
+		return StageValidation
+	}
+	return StageParse
+}
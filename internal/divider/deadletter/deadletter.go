@@ -0,0 +1,87 @@
+// Package deadletter writes and reads dead letters of JobProcessor as
+// newline-delimited JSON.
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
+)
+
+// Writer implements divider.DeadLetterWriter. Every dead letter is written
+// as a JSON object on a line of its own, buffered until Flush.
+type Writer struct {
+	w    *bufio.Writer
+	size int64
+}
+
+// New creates a new Writer.
+func New(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Resume creates a new Writer which appends dead letters to the output of
+// size bytes written previously by another Writer.
+func Resume(w io.Writer, size int64) *Writer {
+	dw := New(w)
+	dw.size = size
+	return dw
+}
+
+// Write writes the dead letter.
+func (w *Writer) Write(dl *divider.DeadLetter) error {
+	b, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	n, err := w.w.Write(append(b, '\n'))
+	w.size += int64(n)
+	return err
+}
+
+// Size returns the number of bytes written including buffered ones.
+func (w *Writer) Size() int64 {
+	return w.size
+}
+
+// Flush writes buffered dead letters to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Provider implements divider.JobProvider over dead letters, so that failed
+// jobs can be processed again. Inputs are parsed like jsonprov does. Jobs
+// keep their IDs of the original run.
+type Provider struct {
+	dec *json.Decoder
+}
+
+// NewProvider creates a new Provider reading dead letters from r.
+func NewProvider(r io.Reader) *Provider {
+	return &Provider{dec: json.NewDecoder(r)}
+}
+
+// More reports whether there is another dead letter.
+func (p *Provider) More() bool {
+	return p.dec.More()
+}
+
+// Next reads the job of the next dead letter. Malformed dead letter input
+// is a non-terminal error.
+func (p *Provider) Next(job *divider.Job) error {
+	var dl divider.DeadLetter
+	if err := p.dec.Decode(&dl); err != nil {
+		return err
+	}
+
+	err := jsonprov.Unmarshal(dl.Input, job)
+	job.ID, job.KeepID = dl.ID, true
+	if err != nil && !errors.Is(err, divider.ErrInvalidInput) {
+		return divider.NewNonTerminalError(err)
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package deadletter_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/deadletter"
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
+	"github.com/b-2019-apt-test/divider/internal/divider/mocks"
+)
+
+const input = `[
+	{"arg1": 1, "arg2": 1},
+	{"arg1": "x", "arg2": 1},
+	{"arg1": 3},
+	{"arg1": 4, "arg2": 0},
+	{"arg1": 5, "arg2": 1}
+]`
+
+func TestDeadLetters(t *testing.T) {
+
+	provider, _ := jsonprov.New(strings.NewReader(input))
+	var buf bytes.Buffer
+	err := mocks.NewJobProcessor().
+		SetJobProvider(provider.SetKeepRaw(true)).
+		SetDeadLetterWriter(deadletter.New(&buf)).
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []divider.DeadLetter{
+		{ID: 1, Input: json.RawMessage(`{"arg1": "x", "arg2": 1}`), Stage: divider.StageParse},
+		{ID: 2, Input: json.RawMessage(`{"arg1": 3}`), Stage: divider.StageValidation},
+		{ID: 3, Input: json.RawMessage(`{"arg1": 4, "arg2": 0}`), Stage: divider.StageDivision},
+	}
+
+	dec := json.NewDecoder(&buf)
+	for _, e := range expected {
+		var dl divider.DeadLetter
+		if err := dec.Decode(&dl); err != nil {
+			t.Fatal(err)
+		}
+		if dl.ID != e.ID || dl.Stage != e.Stage || dl.Error == "" {
+			t.Fatalf("expected %+v, got %+v", e, dl)
+		}
+		var actual, want interface{}
+		json.Unmarshal(dl.Input, &actual)
+		json.Unmarshal(e.Input, &want)
+		if !jsonEqual(actual, want) {
+			t.Fatalf("job %d: expected input %s, got %s", e.ID, e.Input, dl.Input)
+		}
+	}
+	if dec.More() {
+		t.Fatal("unexpected dead letter")
+	}
+}
+
+func TestCheckpointSize(t *testing.T) {
+
+	provider, _ := jsonprov.New(strings.NewReader(input))
+	var buf bytes.Buffer
+	var cp divider.Checkpoint
+	err := mocks.NewJobProcessor().
+		SetJobProvider(provider.SetKeepRaw(true)).
+		SetDeadLetterWriter(deadletter.New(&buf)).
+		SetCheckpoint(0, func(c divider.Checkpoint) error {
+			cp = c
+			return nil
+		}).
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.DeadLetters == 0 || cp.DeadLetters != int64(buf.Len()) {
+		t.Fatalf("expected dead letters size %d, got %d", buf.Len(), cp.DeadLetters)
+	}
+
+	// A resumed writer continues the count.
+	w := deadletter.Resume(&buf, cp.DeadLetters)
+	if err = w.Write(&divider.DeadLetter{ID: 7}); err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Size() != int64(buf.Len()) {
+		t.Fatalf("expected size %d, got %d", buf.Len(), w.Size())
+	}
+}
+
+func TestReplay(t *testing.T) {
+
+	letters := `{"id":7,"input":{"arg1":8,"arg2":2},"stage":"division","error":"e"}
+{"id":9,"input":{"arg1":1,"arg2":0},"stage":"division","error":"e"}
+{"id":12,"input":"garbage","stage":"parse","error":"e"}
+`
+	provider := deadletter.NewProvider(strings.NewReader(letters))
+	reporter := mocks.NewFakeResultReporter()
+	var buf, log bytes.Buffer
+
+	summary, err := mocks.NewJobProcessor().
+		SetJobProvider(provider).
+		SetResultReporter(reporter).
+		SetDeadLetterWriter(deadletter.New(&buf)).
+		SetLogger(slog.New(slog.NewTextHandler(&log, nil))).
+		Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mocks.Validate(t, mocks.TestCase{Name: "replay", Results: []*divider.JobResult{
+		{ID: 7, Value: 4, Valid: true},
+		{ID: 9},
+		{ID: 12},
+	}}, reporter.Results())
+
+	// Logs and the summary tell the original IDs too.
+	if len(summary.Errors) != 2 {
+		t.Fatalf("unexpected error samples: %+v", summary.Errors)
+	}
+	for _, e := range summary.Errors {
+		if e.ID != 9 && e.ID != 12 {
+			t.Fatalf("unexpected error sample: %+v", e)
+		}
+	}
+	if !strings.Contains(log.String(), "job_id=9") || !strings.Contains(log.String(), "job_id=12") {
+		t.Fatalf("expected original IDs in log:\n%s", log.String())
+	}
+
+	var ids []uint64
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var dl divider.DeadLetter
+		if err := dec.Decode(&dl); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, dl.ID)
+	}
+	if len(ids) != 2 || ids[0] != 9 || ids[1] != 12 {
+		t.Fatalf("unexpected dead letters: %v", ids)
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
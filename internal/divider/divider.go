@@ -3,8 +3,12 @@ package divider
 import (
 	"context"
 	"errors"
-	"log"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/b-2019-apt-test/divider/pkg/div"
 	"github.com/b-2019-apt-test/divider/pkg/pool"
@@ -14,15 +18,36 @@ import (
 type Job struct {
 	Arg1, Arg2 int
 	Valid      bool
+	// Raw is the job as it was read, if the provider keeps it.
+	Raw []byte `json:"-"`
+	// Source is the name of the input the job was read from and Index is
+	// the number of the job in the input, if the provider tells them.
+	Source string `json:"-"`
+	Index  uint64 `json:"-"`
+	// InputID is the ID of the job given in the input, if any. Integer IDs
+	// are kept in decimal.
+	InputID string `json:"-"`
+	// ID is the ID results of the job keep instead of the sequential one if
+	// KeepID is set, e.g. the ID of a replayed job in the original run.
+	ID     uint64 `json:"-"`
+	KeepID bool   `json:"-"`
 }
 
 // JobResult represents an outcome of a particular job processing. ID member
-// is a sequential number of the corresponding job. Valid member specifies
-// whether the job was processed correctly.
+// is a sequential number of the corresponding job, unless the job keeps its
+// own ID. Valid member specifies whether the job was processed correctly.
+// If not, Reason and Message tell why.
 type JobResult struct {
-	ID    uint64
-	Value int
-	Valid bool
+	ID      uint64
+	Value   int
+	Valid   bool
+	Reason  Reason
+	Message string
+	// Source, Index and InputID are copied from the job. InputID may be
+	// changed by IDPolicy.
+	Source  string
+	Index   uint64
+	InputID string
 }
 
 // JobProcessor does all the job - processes jobs in accordance to the aptitude
@@ -31,13 +56,49 @@ type JobProcessor struct {
 	d    div.Divider
 	prov JobProvider
 	rrep ResultReporter
-	log  *log.Logger
+	dlw  DeadLetterWriter
+	log  *slog.Logger
 
-	pool *pool.Pool
-	wc   uint
+	pool  *pool.Pool
+	wc    uint
+	chunk uint
+	steal bool
 
+	// mu guards pool and cancel which are accessed by Stop and Stats
+	// concurrently with Start.
+	mu     sync.Mutex
 	cancel context.CancelFunc
 
+	samples uint
+	tally   tally
+
+	closeRep bool
+	repErr   error
+
+	observers observers
+	obs       Observer
+	stages    []Stage
+
+	idPolicy IDPolicy
+	ids      map[string]bool
+
+	shard Shard
+
+	maxDuration time.Duration
+	spent       atomic.Bool
+
+	thresholds Thresholds
+	thr        *thresholdCheck
+	thrErr     *ThresholdError
+
+	cpEvery time.Duration
+	cpSave  func(Checkpoint) error
+	// cpOn tells whether checkpoints are saved in the current run.
+	cpOn     bool
+	resume   *Checkpoint
+	skip     map[uint64]bool
+	progress progress
+
 	c uint64
 	p uint64
 }
@@ -49,11 +110,61 @@ type JobProvider interface {
 	More() bool
 }
 
-// ResultReporter delievers results of job processing.
+// ResultReporter delievers results of job processing. If ResultReporter
+// implements Flusher, JobProcessor flushes it after the last result.
 type ResultReporter interface {
 	Report(*JobResult) error
 }
 
+// Flusher is implemented by result reporters which buffer their output.
+type Flusher interface {
+	Flush() error
+}
+
+// Categories of errors. Errors returned by JobProcessor and its providers
+// and reporters match one of them with errors.Is.
+var (
+	// ErrNotConfigured is the category of errors caused by JobProcessor
+	// started without mandatory components.
+	ErrNotConfigured = errors.New("not configured")
+
+	// ErrInvalidInput is the category of NonTerminalError: a job is
+	// rejected, but processing goes on.
+	ErrInvalidInput = errors.New("invalid input")
+
+	// ErrMalformedInput is the category of terminal errors of JobProvider
+	// caused by input which can not be read any further.
+	ErrMalformedInput = errors.New("malformed input")
+
+	// ErrReporter is the category of ReporterError.
+	ErrReporter = errors.New("reporter failure")
+
+	// ErrBudgetExceeded is returned by JobProcessor stopped by the run time
+	// budget. The jobs read before are completed, Summary.NextID and the
+	// checkpoint tell how far the run got.
+	ErrBudgetExceeded = errors.New("run time budget exceeded")
+)
+
+// ReporterError is returned by JobProcessor if ResultReporter failed. It
+// terminates processing.
+type ReporterError struct {
+	Err error
+}
+
+func (e *ReporterError) Error() string {
+	return "unable to write report: " + e.Err.Error()
+}
+
+// Unwrap returns the error of ResultReporter.
+func (e *ReporterError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrReporter.
+func (e *ReporterError) Is(target error) bool {
+	return target == ErrReporter
+}
+
 // NonTerminalError specifies that JobProcessor must skip processing of the job.
 // The error should only raised by JobProvider.
 type NonTerminalError struct {
@@ -66,35 +177,69 @@ func NewNonTerminalError(err error) *NonTerminalError {
 }
 
 func (e *NonTerminalError) Error() string {
+	if e.err == nil {
+		return "non-terminal error"
+	}
 	return e.err.Error()
 }
 
+// Unwrap returns the wrapped error.
+func (e *NonTerminalError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is ErrInvalidInput.
+func (e *NonTerminalError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// configError is an error of ErrNotConfigured category.
+type configError string
+
+func (e configError) Error() string {
+	return string(e)
+}
+
+func (e configError) Is(target error) bool {
+	return target == ErrNotConfigured
+}
+
 type worker struct {
 	div.Divider
-	log *log.Logger
+	obs Observer
 }
 
 type workerTask struct {
 	job    *Job
 	result *JobResult
+	err    error
+
+	// seq is the number of the job in the run. Progress is tracked by it,
+	// since results may keep the IDs given by the provider.
+	seq uint64
+
+	// end is the input offset where the job ends.
+	end int64
+	// skip is set for jobs completed by the resumed run.
+	skip bool
 }
 
 var (
 	// ErrJobProviderNotSpecified returns if JobProcessor started without
 	// specified JobProvider.
-	ErrJobProviderNotSpecified = errors.New("JobProvider not specified")
+	ErrJobProviderNotSpecified = configError("JobProvider not specified")
 
 	// ErrResultReporterNotSpecified returns if JobProcessor started without
 	// specified ResultReporter.
-	ErrResultReporterNotSpecified = errors.New("ResultReporter not specified")
+	ErrResultReporterNotSpecified = configError("ResultReporter not specified")
 
 	// ErrDividerNotSpecified returns if JobProcessor started without
 	// specified div.Divider for processing jobs.
-	ErrDividerNotSpecified = errors.New("Divider not specified")
+	ErrDividerNotSpecified = configError("Divider not specified")
 
 	// ErrLoggerNotSpecified returned if JobProcessor started without
-	// specified log.Logger.
-	ErrLoggerNotSpecified = errors.New("Logger not specified")
+	// specified slog.Logger.
+	ErrLoggerNotSpecified = configError("Logger not specified")
 
 	// ErrArg1Missing specifies that a job does not have field "arg1"
 	ErrArg1Missing = NewNonTerminalError(errors.New(`"arg1" field missing`))
@@ -105,86 +250,213 @@ var (
 
 // NewJobProcessor creates a new JobProcessor.
 func NewJobProcessor() *JobProcessor {
-	return new(JobProcessor)
+	return &JobProcessor{samples: DefaultErrorSamples}
 }
 
 func (p *JobProcessor) newWorker() *worker {
-	return &worker{
-		Divider: p.d,
-		log:     p.log}
+	return &worker{Divider: p.d, obs: p.obs}
 }
 
 func (p *JobProcessor) newWorkerTask() *workerTask {
 	task := &workerTask{
 		job:    &Job{},
-		result: &JobResult{ID: p.c}}
+		result: &JobResult{ID: p.c},
+		seq:    p.c}
 	p.c++
 	return task
 }
 
-// Process implements pool.Worker.
-func (w *worker) Process(x interface{}) interface{} {
+// TryProcess implements pool.FallibleWorker. Division errors are delivered
+// by the pool as *pool.Failure with the task as the job.
+func (w *worker) TryProcess(x interface{}) (interface{}, error) {
 
 	task := x.(*workerTask)
-	if !task.job.Valid {
-		return task.result
+	if task.skip || !task.job.Valid {
+		return task, nil
 	}
 
-	var err error
-	task.result.Value, err = w.Div(task.job.Arg1, task.job.Arg2)
+	if w.obs != nil {
+		return w.observe(task)
+	}
+
+	value, err := w.Div(task.job.Arg1, task.job.Arg2)
+	if err != nil {
+		return nil, err
+	}
+	task.result.Value = value
+	task.result.Valid = true
+
+	return task, nil
+}
+
+func (w *worker) observe(task *workerTask) (interface{}, error) {
+	start := time.Now()
+	value, err := w.div(task.job.Arg1, task.job.Arg2)
+	d := time.Since(start)
+	e := JobEvent{Job: task.job, Result: task.result, Duration: d}
 	if err != nil {
-		w.log.Printf("Job %d processed with error: %v", task.result.ID, err)
+		task.err = err
+		e.Err, e.Reason = err, reasonOf(task)
 	} else {
+		task.result.Value = value
 		task.result.Valid = true
 	}
+	w.obs.JobDivided(e)
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
 
-	return task.result
+// div divides with the divider. A panic is returned as *pool.PanicError, so
+// that observers see it like any other division error.
+func (w *worker) div(a, b int) (value int, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &pool.PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return w.Div(a, b)
 }
 
-// Start initializes worker pool and begins processing of jobs.
+// Start initializes worker pool and processes jobs until the provider is
+// exhausted or Stop is called. It is a shorthand for Run without summary.
 func (p *JobProcessor) Start() error {
+	_, err := p.Run(context.Background())
+	return err
+}
+
+// Run initializes worker pool and processes jobs until the provider is
+// exhausted, ctx is done or Stop is called. Cancellation is not an error:
+// jobs read by then are processed and reported, and Run returns nil.
+// The summary describes all the results reported, even if the run failed.
+//
+// If ResultReporter fails, reading of jobs is canceled, the jobs read by
+// then are processed but not reported, and Run returns *ReporterError.
+func (p *JobProcessor) Run(ctx context.Context) (Summary, error) {
 
 	if p.prov == nil {
-		return ErrJobProviderNotSpecified
+		return Summary{}, ErrJobProviderNotSpecified
 	}
 	if p.rrep == nil {
-		return ErrResultReporterNotSpecified
+		return Summary{}, ErrResultReporterNotSpecified
 	}
 	if p.log == nil {
-		return ErrLoggerNotSpecified
+		return Summary{}, ErrLoggerNotSpecified
 	}
 	if p.d == nil {
-		return ErrDividerNotSpecified
+		return Summary{}, ErrDividerNotSpecified
 	}
 
-	p.log.Println("Processing started.")
-	p.pool = pool.New(p.newWorker(), p.wc)
+	p.log.Info("processing started")
+	start := time.Now()
+	p.obs = p.observer()
+	if p.obs != nil {
+		p.obs.RunStarted(RunEvent{Time: start})
+	}
 
-	done := make(chan bool)
-	go p.reportResults(done)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	var ctx context.Context
-	ctx, p.cancel = context.WithCancel(context.Background())
-	err := p.enqueueJobs(ctx)
+	p.spent.Store(false)
+	if p.maxDuration > 0 {
+		budget := time.AfterFunc(p.maxDuration, func() {
+			p.spent.Store(true)
+			cancel()
+		})
+		defer budget.Stop()
+	}
 
-	// We should not wait for result reporter to finish if
-	// an error has occurred on the job provider side.
-	// The 'done' chan can potentially leak in that case
-	// but it is assumed that the program itself exits on
-	// a JobProcessor error.
-	if err != nil {
-		return err
+	p.mu.Lock()
+	p.pool = pool.New(pool.Fallible(p.newWorker()), p.wc, p.poolOptions()...)
+	p.cancel = cancel
+	p.mu.Unlock()
+
+	p.tally = tally{samples: int(p.samples)}
+	p.repErr = nil
+	p.thr, p.thrErr = newThresholdCheck(p.thresholds), nil
+	p.ids = make(map[string]bool)
+	p.progress = newProgress(0, 0)
+	_, positioned := p.prov.(Positioner)
+	p.cpOn = p.cpSave != nil && positioned
+	if p.cpSave != nil && !positioned {
+		p.log.Warn("checkpoints disabled, job provider does not tell input offsets")
 	}
+	if p.resume != nil {
+		p.c = p.resume.NextID
+		p.progress = newProgress(p.resume.NextID, p.resume.Offset)
+	}
+
+	done := make(chan bool)
+	go p.reportResults(done, cancel)
 
+	err := p.enqueueJobs(ctx)
+
+	// The pool is closed by now, so the result reporter
+	// finishes as soon as it writes the remaining results.
 	<-done
 	close(done)
 
-	return nil
+	if err == nil {
+		err = p.repErr
+	}
+	if err == nil && p.thrErr != nil {
+		err = p.thrErr
+	}
+	if p.cpOn && p.repErr == nil {
+		p.saveCheckpoint()
+	}
+	if cerr := p.closeReporter(); err == nil && cerr != nil {
+		err = &ReporterError{cerr}
+	}
+
+	summary := p.tally.summary(time.Since(start))
+	summary.NextID = p.progress.next
+	summary.Threshold = p.thrErr
+	if p.obs != nil {
+		p.obs.RunEnded(RunEvent{Time: time.Now(), Summary: summary, Err: err})
+	}
+	return summary, err
+}
+
+// closeReporter flushes the reporter and the dead-letter writer and closes
+// them if requested.
+func (p *JobProcessor) closeReporter() error {
+	err := p.closeOutput(p.rrep)
+	if p.dlw != nil {
+		if cerr := p.closeOutput(p.dlw); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (p *JobProcessor) closeOutput(out interface{}) error {
+	var err error
+	if f, ok := out.(Flusher); ok && p.repErr == nil {
+		err = f.Flush()
+	}
+	if c, ok := out.(io.Closer); ok && p.closeRep {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (p *JobProcessor) poolOptions() []pool.Option {
+	opts := []pool.Option{pool.ChunkSize(p.chunk)}
+	if p.steal {
+		opts = append(opts, pool.WorkStealing())
+	}
+	return opts
 }
 
 // Stop cancels reading, what, in turn, closes the worker pool and stops
 // writing results.
 func (p *JobProcessor) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if p.cancel != nil {
 		p.cancel()
 	}
@@ -195,24 +467,45 @@ func (p *JobProcessor) Processed() uint64 {
 	return atomic.LoadUint64(&p.p)
 }
 
+// Stats returns counters of the worker pool. It is safe to call Stats while
+// jobs are being processed. Zero Stats is returned if processing has not
+// been started.
+func (p *JobProcessor) Stats() pool.Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pool == nil {
+		return pool.Stats{}
+	}
+	return p.pool.Stats()
+}
+
 // enqueueJobs puts jobs received from JobProvider to worker pool.
 func (p *JobProcessor) enqueueJobs(ctx context.Context) error {
 
 	defer p.pool.Close()
 	var task *workerTask
+	pos, _ := p.prov.(Positioner)
+	skipper, _ := p.prov.(Skipper)
+	if p.shard.ByInputID {
+		skipper = nil
+	}
 
 	for p.prov.More() {
 		select {
 		case <-ctx.Done():
+			if p.spent.Load() {
+				return ErrBudgetExceeded
+			}
 			return nil
 		default:
 			task = p.newWorkerTask()
-			if err := p.prov.Next(task.job); err != nil {
-				if _, ok := err.(*NonTerminalError); !ok {
-					return err
-				}
-				p.log.Printf("Job %d processing error: %v", task.result.ID, err)
+			if err := p.read(task, skipper); err != nil {
+				return err
 			}
+			if pos != nil {
+				task.end = pos.Offset()
+			}
+			task.skip = task.skip || p.skip[task.seq]
 			p.pool.Put(task)
 		}
 	}
@@ -220,20 +513,203 @@ func (p *JobProcessor) enqueueJobs(ctx context.Context) error {
 	return nil
 }
 
-func (p *JobProcessor) reportResults(done chan bool) {
-	for result := range p.pool.Consume() {
-		if err := p.rrep.Report(result.(*JobResult)); err != nil {
-			// Do not try to handle error.
-			// If we can't write results, we must exit immediately.
-			// Perhaps it worth to log a report on work, even in
-			// case of failure.
-			p.log.Fatalf("Unable to write report: %v", err)
+// read reads the next job to the task and checks it. Jobs of other shards
+// are marked skipped. Only terminal errors are returned.
+func (p *JobProcessor) read(task *workerTask, skipper Skipper) error {
+	if skipper != nil && !p.shard.owns(task.seq, "") {
+		task.skip = true
+		if err := skipper.Skip(); err != nil && !errors.Is(err, ErrInvalidInput) {
+			return err
+		}
+		return nil
+	}
+
+	var t time.Time
+	if p.obs != nil {
+		t = time.Now()
+	}
+	err := p.prov.Next(task.job)
+	if err != nil {
+		var nterr *NonTerminalError
+		if !errors.As(err, &nterr) {
+			return err
+		}
+	}
+	if !p.shard.owns(task.seq, task.job.InputID) {
+		task.skip = true
+		return nil
+	}
+	if task.job.KeepID {
+		task.result.ID = task.job.ID
+	}
+
+	task.result.Source, task.result.Index = task.job.Source, task.job.Index
+	idErr := p.checkID(task)
+	if err != nil {
+		p.reject(task, err, t)
+		return nil
+	}
+	if p.obs != nil {
+		p.obs.JobRead(JobEvent{Job: task.job, Duration: time.Since(t)})
+	}
+	if idErr != nil {
+		p.reject(task, idErr, t)
+	} else if len(p.stages) > 0 {
+		if err = p.applyStages(task); err != nil {
+			p.reject(task, err, t)
+		}
+	}
+	return nil
+}
+
+// reject marks the job invalid. Read is the time the job reading started.
+func (p *JobProcessor) reject(task *workerTask, err error, read time.Time) {
+	task.job.Valid = false
+	task.err = err
+	p.log.Warn("job rejected", "job_id", task.result.ID,
+		"stage", stageOf(task), "reason", reasonOf(task), "error", err)
+	if p.obs != nil {
+		p.obs.JobRejected(JobEvent{Job: task.job, Duration: time.Since(read),
+			Err: err, Reason: reasonOf(task)})
+	}
+}
+
+// reportResults reports results until the pool is closed. If the reporter
+// fails, enqueueing is canceled and the rest of the results is drained, so
+// the pool could be closed.
+func (p *JobProcessor) reportResults(done chan bool, cancel context.CancelFunc) {
+
+	var tick <-chan time.Time
+	if p.cpOn && p.cpEvery > 0 {
+		ticker := time.NewTicker(p.cpEvery)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	if p.chunk > 1 {
+		for chunk := range p.pool.ConsumeChunks() {
+			for _, result := range chunk {
+				p.report(result, cancel)
+			}
+			p.checkpointOn(tick, cancel)
+		}
+	} else {
+		for result := range p.pool.Consume() {
+			p.report(result, cancel)
+			p.checkpointOn(tick, cancel)
 		}
-		atomic.AddUint64(&p.p, 1)
 	}
 	done <- true
 }
 
+// checkpointOn saves a checkpoint if it is time to.
+func (p *JobProcessor) checkpointOn(tick <-chan time.Time, cancel context.CancelFunc) {
+	select {
+	case <-tick:
+		if p.repErr == nil && p.saveCheckpoint() != nil && p.repErr != nil {
+			cancel()
+		}
+	default:
+	}
+}
+
+// saveCheckpoint flushes the reporter, so its output matches the
+// checkpoint, and saves the checkpoint. Failure to save a checkpoint does
+// not stop processing, but the reporter failure does.
+func (p *JobProcessor) saveCheckpoint() error {
+	for _, out := range []interface{}{p.rrep, p.dlw} {
+		if f, ok := out.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				p.repErr = &ReporterError{err}
+				p.log.Error("checkpoint failed", "error", p.repErr)
+				return p.repErr
+			}
+		}
+	}
+	cp := p.progress.checkpoint()
+	if s, ok := p.rrep.(Sizer); ok {
+		cp.Output = s.Size()
+	}
+	if s, ok := p.rrep.(MultiSizer); ok {
+		cp.Outputs = s.Sizes()
+	}
+	if s, ok := p.dlw.(Sizer); ok {
+		cp.DeadLetters = s.Size()
+	}
+	if err := p.cpSave(cp); err != nil {
+		p.log.Warn("checkpoint failed", "error", err)
+		return err
+	}
+	p.log.Debug("checkpoint saved", "next_id", cp.NextID, "offset", cp.Offset)
+	return nil
+}
+
+// report writes the job result. Failed jobs are reported as invalid, so
+// every job gets its result row.
+func (p *JobProcessor) report(x interface{}, cancel context.CancelFunc) {
+	if p.repErr != nil {
+		return
+	}
+	task, ok := x.(*workerTask)
+	if !ok {
+		failure := x.(*pool.Failure)
+		task = failure.Job.(*workerTask)
+		task.result.Value, task.result.Valid = 0, false
+		task.err = failure.Err
+		p.logFailure(task)
+	}
+	if !task.skip {
+		if !task.result.Valid {
+			task.result.Reason = reasonOf(task)
+			if task.err != nil {
+				task.result.Message = task.err.Error()
+			}
+		}
+		var t time.Time
+		if p.obs != nil {
+			t = time.Now()
+		}
+		if err := p.rrep.Report(task.result); err != nil {
+			p.repErr = &ReporterError{err}
+			p.log.Error("report failed", "job_id", task.result.ID, "error", p.repErr)
+			cancel()
+			return
+		}
+		if p.dlw != nil && task.err != nil {
+			if err := p.dlw.Write(newDeadLetter(task)); err != nil {
+				p.repErr = &ReporterError{err}
+				p.log.Error("dead letter failed", "job_id", task.result.ID, "error", p.repErr)
+				cancel()
+				return
+			}
+		}
+		if p.obs != nil {
+			p.obs.ResultReported(JobEvent{Job: task.job, Result: task.result,
+				Duration: time.Since(t), Err: task.err, Reason: task.result.Reason})
+		}
+		p.tally.add(task)
+		atomic.AddUint64(&p.p, 1)
+		if p.thr != nil && p.thrErr == nil {
+			if p.thrErr = p.thr.add(task); p.thrErr != nil {
+				p.log.Error("processing aborted", "error", p.thrErr)
+				cancel()
+			}
+		}
+	}
+	p.progress.complete(task.seq, task.end)
+}
+
+func (p *JobProcessor) logFailure(task *workerTask) {
+	var perr *pool.PanicError
+	if errors.As(task.err, &perr) {
+		p.log.Error("job panicked", "job_id", task.result.ID, "stage", StageDivision,
+			"error", perr, "stack", string(perr.Stack))
+		return
+	}
+	p.log.Warn("job failed", "job_id", task.result.ID, "stage", StageDivision,
+		"reason", reasonOf(task), "error", task.err)
+}
+
 // SetJobProvider specifies a provider of jobs to be processed.
 func (p *JobProcessor) SetJobProvider(prov JobProvider) *JobProcessor {
 	p.prov = prov
@@ -247,8 +723,59 @@ func (p *JobProcessor) SetResultReporter(rrep ResultReporter) *JobProcessor {
 	return p
 }
 
-// SetLogger specifies a logger to be used with JobProcessor and its workers.
-func (p *JobProcessor) SetLogger(l *log.Logger) *JobProcessor {
+// SetDeadLetterWriter specifies a writer of jobs failed to be processed.
+// Failed jobs are reported as invalid results regardless of it.
+func (p *JobProcessor) SetDeadLetterWriter(w DeadLetterWriter) *JobProcessor {
+	p.dlw = w
+	return p
+}
+
+// SetShard makes JobProcessor process only the jobs of the shard.
+func (p *JobProcessor) SetShard(s Shard) *JobProcessor {
+	p.shard = s
+	return p
+}
+
+// SetMaxDuration sets the run time budget. Once it is spent, the run stops
+// as if it was canceled and returns ErrBudgetExceeded. Zero means no limit.
+func (p *JobProcessor) SetMaxDuration(d time.Duration) *JobProcessor {
+	p.maxDuration = d
+	return p
+}
+
+// SetThresholds makes JobProcessor stop processing once the count or the
+// ratio of invalid results exceeds the thresholds. Run returns
+// ThresholdError then, which is also recorded in Summary.
+func (p *JobProcessor) SetThresholds(t Thresholds) *JobProcessor {
+	p.thresholds = t
+	return p
+}
+
+// SetIDPolicy specifies the policy for missing and duplicate input IDs.
+// Default is IDKeep.
+func (p *JobProcessor) SetIDPolicy(policy IDPolicy) *JobProcessor {
+	p.idPolicy = policy
+	return p
+}
+
+// AddStage appends the stage to the pre-processing stages jobs pass before
+// division.
+func (p *JobProcessor) AddStage(s Stage) *JobProcessor {
+	p.stages = append(p.stages, s)
+	return p
+}
+
+// AddObserver registers the observer of processing events. Observers are
+// called in order of registration. Without observers events cost nothing.
+func (p *JobProcessor) AddObserver(o Observer) *JobProcessor {
+	p.observers = append(p.observers, o)
+	return p
+}
+
+// SetLogger specifies a logger to be used with JobProcessor. Rejected and
+// failed jobs are logged at warning level with job_id, stage, reason and
+// error attributes, terminal failures at error level.
+func (p *JobProcessor) SetLogger(l *slog.Logger) *JobProcessor {
 	p.log = l
 	return p
 }
@@ -259,6 +786,58 @@ func (p *JobProcessor) SetWorkersCount(workers uint) *JobProcessor {
 	return p
 }
 
+// SetChunkSize specifies count of jobs to be passed to the worker pool and
+// back at once. Chunks reduce synchronization overhead when the division
+// itself is cheap. Values less than 2 disable chunking.
+func (p *JobProcessor) SetChunkSize(n uint) *JobProcessor {
+	p.chunk = n
+	return p
+}
+
+// SetWorkStealing makes the worker pool use work-stealing scheduler instead
+// of the single channel shared by all workers.
+func (p *JobProcessor) SetWorkStealing(steal bool) *JobProcessor {
+	p.steal = steal
+	return p
+}
+
+// SetCloseReporter makes JobProcessor close ResultReporter implementing
+// io.Closer once all results are reported, even if the run failed.
+func (p *JobProcessor) SetCloseReporter(close bool) *JobProcessor {
+	p.closeRep = close
+	return p
+}
+
+// SetCheckpoint makes JobProcessor save a checkpoint with save function
+// every period of time and once the run is over. Checkpoints are saved from
+// the result reporting goroutine, after the reporter is flushed. Without
+// offsets a checkpoint can not be resumed, so JobProvider must be a
+// Positioner, otherwise checkpoints are not saved.
+func (p *JobProcessor) SetCheckpoint(every time.Duration, save func(Checkpoint) error) *JobProcessor {
+	p.cpEvery = every
+	p.cpSave = save
+	return p
+}
+
+// SetResume makes JobProcessor continue the run interrupted at the
+// checkpoint: job IDs start from cp.NextID and jobs listed in cp.Done are
+// not reported again. JobProvider must be positioned at cp.Offset.
+func (p *JobProcessor) SetResume(cp Checkpoint) *JobProcessor {
+	p.resume = &cp
+	p.skip = make(map[uint64]bool, len(cp.Done))
+	for _, id := range cp.Done {
+		p.skip[id] = true
+	}
+	return p
+}
+
+// SetErrorSamples specifies the maximum count of error samples in Summary.
+// Default is DefaultErrorSamples.
+func (p *JobProcessor) SetErrorSamples(n uint) *JobProcessor {
+	p.samples = n
+	return p
+}
+
 // SetDivider sets division method.
 func (p *JobProcessor) SetDivider(d div.Divider) *JobProcessor {
 	p.d = d
@@ -1,33 +1,344 @@
 package divider_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/csvrep"
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
 	"github.com/b-2019-apt-test/divider/internal/divider/mocks"
+	"github.com/b-2019-apt-test/divider/pkg/div/timeoutdiv"
+	"github.com/b-2019-apt-test/divider/pkg/pool"
 )
 
-var runner = func(t *testing.T, test mocks.TestCase) {
+const benchJobs = 1000000
 
-	reporter := mocks.NewFakeResultReporter()
-	proc := mocks.NewJobProcessor().
-		SetJobProvider(mocks.NewFakeJobProvider(test.Jobs)).
-		SetResultReporter(reporter)
+// runner returns a test case runner of JobProcessor configured by setup.
+// Results are sorted by ID, as several workers report them out of order.
+func runner(setup func(*divider.JobProcessor) *divider.JobProcessor) func(*testing.T, mocks.TestCase) {
+	return func(t *testing.T, test mocks.TestCase) {
+
+		reporter := mocks.NewFakeResultReporter()
+		proc := mocks.NewJobProcessor().
+			SetJobProvider(mocks.NewFakeJobProvider(test.Jobs)).
+			SetResultReporter(reporter)
+		if setup != nil {
+			proc = setup(proc)
+		}
 
-	if err := proc.Start(); err != test.Err {
-		t.Fatalf("expected err %v, got: %v", test.Err, err)
+		if err := proc.Start(); err != test.Err {
+			t.Fatalf("expected err %v, got: %v", test.Err, err)
+		}
+
+		results := reporter.Results()
+		sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+		mocks.Validate(t, test, results)
 	}
+}
 
-	mocks.Validate(t, test, reporter.Results())
+// poolConfigs are the worker pool settings test cases are run with.
+var poolConfigs = []struct {
+	name  string
+	setup func(*divider.JobProcessor) *divider.JobProcessor
+}{
+	{"Default", nil},
+	{"Chunked", func(p *divider.JobProcessor) *divider.JobProcessor {
+		return p.SetChunkSize(2)
+	}},
+	{"WorkStealing", func(p *divider.JobProcessor) *divider.JobProcessor {
+		return p.SetWorkersCount(8).SetWorkStealing(true)
+	}},
+	{"WorkStealingChunked", func(p *divider.JobProcessor) *divider.JobProcessor {
+		return p.SetWorkersCount(8).SetWorkStealing(true).SetChunkSize(4)
+	}},
 }
 
 func TestCases(t *testing.T) {
-	mocks.RunTestCases(t, runner)
+
+	// Enough jobs to keep several workers busy.
+	many := mocks.TestCase{Name: "Many jobs"}
+	for i := 0; i < 1000; i++ {
+		many.Jobs = append(many.Jobs, &divider.Job{Arg1: i, Arg2: i%7 + 1, Valid: true})
+		many.Results = append(many.Results, &divider.JobResult{ID: uint64(i), Value: i / (i%7 + 1), Valid: true})
+	}
+
+	for _, c := range poolConfigs {
+		t.Run(c.name, func(t *testing.T) {
+			run := runner(c.setup)
+			mocks.RunTestCases(t, run)
+			run(t, many)
+		})
+	}
 }
 
 func TestWorkerSkipsInvalidJob(t *testing.T) {
-	runner(t, mocks.InvalidJob)
+	runner(nil)(t, mocks.InvalidJob)
+}
+
+func TestWorkerPanic(t *testing.T) {
+
+	reporter := mocks.NewFakeResultReporter()
+	proc := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(mocks.ProcessingError.Jobs)).
+		SetResultReporter(reporter).
+		SetDivider(mocks.PanicDivider{})
+
+	if err := proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	mocks.Validate(t, mocks.ProcessingError, reporter.Results())
+}
+
+func TestResultReasons(t *testing.T) {
+
+	input := `[{"arg1": 4, "arg2": 2}, {"arg2": 1}, {"arg1": 1}, {"arg1": "x", "arg2": 1}, {"arg1": 1, "arg2": 0}]`
+	expected := []divider.Reason{
+		divider.ReasonNone,
+		divider.ReasonArg1Missing,
+		divider.ReasonArg2Missing,
+		divider.ReasonTypeMismatch,
+		divider.ReasonDivZero,
+	}
+
+	provider, _ := jsonprov.New(strings.NewReader(input))
+	reporter := mocks.NewFakeResultReporter()
+	err := mocks.NewJobProcessor().
+		SetJobProvider(provider).
+		SetResultReporter(reporter).
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, result := range reporter.Results() {
+		if result.Reason != expected[i] {
+			t.Fatalf("job %d: expected reason %q, got %q", i, expected[i], result.Reason)
+		}
+		if (result.Message == "") != (expected[i] == divider.ReasonNone) {
+			t.Fatalf("job %d: unexpected message %q", i, result.Message)
+		}
+	}
+
+	reporter = mocks.NewFakeResultReporter()
+	err = mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(mocks.ProcessingError.Jobs)).
+		SetResultReporter(reporter).
+		SetDivider(mocks.PanicDivider{}).
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason := reporter.Results()[1].Reason; reason != divider.ReasonPanic {
+		t.Fatalf("expected reason %q, got %q", divider.ReasonPanic, reason)
+	}
+}
+
+func TestLogFields(t *testing.T) {
+
+	var buf bytes.Buffer
+	provider, _ := jsonprov.New(strings.NewReader(`[{"arg1": 1}, {"arg1": 1, "arg2": 0}]`))
+	err := mocks.NewJobProcessor().
+		SetJobProvider(provider).
+		SetLogger(slog.New(slog.NewJSONHandler(&buf, nil))).
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records []map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r map[string]interface{}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := r["job_id"]; ok {
+			records = append(records, r)
+		}
+	}
+
+	expected := []struct {
+		id     float64
+		stage  string
+		reason divider.Reason
+	}{
+		{0, divider.StageValidation, divider.ReasonArg2Missing},
+		{1, divider.StageDivision, divider.ReasonDivZero},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d job records, got: %v", len(expected), records)
+	}
+	for i, e := range expected {
+		r := records[i]
+		if r["level"] != "WARN" || r["job_id"] != e.id || r["stage"] != e.stage ||
+			r["reason"] != string(e.reason) || r["error"] == "" {
+			t.Fatalf("unexpected record: %v", r)
+		}
+	}
+}
+
+func TestObserver(t *testing.T) {
+
+	var started, ended, read, rejected, divided, divErrors, reported atomic.Int64
+	var summary divider.Summary
+	obs := &divider.ObserverFuncs{
+		OnRunStart: func(divider.RunEvent) { started.Add(1) },
+		OnRunEnd: func(e divider.RunEvent) {
+			ended.Add(1)
+			summary = e.Summary
+		},
+		OnJobRead:     func(divider.JobEvent) { read.Add(1) },
+		OnJobRejected: func(divider.JobEvent) { rejected.Add(1) },
+		OnJobDivided: func(e divider.JobEvent) {
+			divided.Add(1)
+			if e.Err != nil {
+				divErrors.Add(1)
+			}
+		},
+		OnResultReported: func(e divider.JobEvent) {
+			if e.Result == nil || e.Job == nil {
+				t.Error("result reported without job or result")
+			}
+			reported.Add(1)
+		},
+	}
+
+	// One job is rejected, one fails on division.
+	provider, _ := jsonprov.New(strings.NewReader(`[{"arg1": 1}, {"arg1": 1, "arg2": 0},
+		{"arg1": 1, "arg2": 1}, {"arg1": 2, "arg2": 1}, {"arg1": 3, "arg2": 1},
+		{"arg1": 4, "arg2": 1}, {"arg1": 5, "arg2": 1}]`))
+
+	err := mocks.NewJobProcessor().
+		SetJobProvider(provider).
+		SetWorkersCount(4).
+		SetChunkSize(2).
+		AddObserver(obs).
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := []int64{started.Load(), ended.Load(), read.Load(), rejected.Load(),
+		divided.Load(), divErrors.Load(), reported.Load()}
+	expected := []int64{1, 1, 6, 1, 6, 1, 7}
+	for i := range counts {
+		if counts[i] != expected[i] {
+			t.Fatalf("expected event counts %v, got %v", expected, counts)
+		}
+	}
+	if summary.Total != 7 || summary.InvalidInput != 1 || summary.DivErrors != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestObserverPanic(t *testing.T) {
+
+	var divided, panics atomic.Int64
+	obs := &divider.ObserverFuncs{
+		OnJobDivided: func(e divider.JobEvent) {
+			divided.Add(1)
+			var perr *pool.PanicError
+			if errors.As(e.Err, &perr) && e.Reason == divider.ReasonPanic {
+				panics.Add(1)
+			}
+		},
+	}
+
+	reporter := mocks.NewFakeResultReporter()
+	summary, err := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(mocks.ProcessingError.Jobs)).
+		SetResultReporter(reporter).
+		SetDivider(mocks.PanicDivider{}).
+		AddObserver(obs).
+		Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mocks.Validate(t, mocks.ProcessingError, reporter.Results())
+	if divided.Load() != int64(len(mocks.ProcessingError.Jobs)) || panics.Load() != 1 || summary.DivErrors != 1 {
+		t.Fatalf("expected %d jobs divided with 1 panic, got %d with %d",
+			len(mocks.ProcessingError.Jobs), divided.Load(), panics.Load())
+	}
+	for _, r := range reporter.Results() {
+		if !r.Valid && r.Reason != divider.ReasonPanic {
+			t.Fatalf("expected panic reason, got %+v", *r)
+		}
+	}
+}
+
+func TestThresholds(t *testing.T) {
+
+	// Every other job fails on division.
+	jobs := make([]*divider.Job, 1000)
+	for i := range jobs {
+		jobs[i] = &divider.Job{Arg1: i, Arg2: i % 2, Valid: true}
+	}
+
+	tests := []struct {
+		thresholds divider.Thresholds
+		trigger    string
+		value      float64
+	}{
+		{divider.Thresholds{MaxInvalid: 5}, divider.TriggerMaxInvalid, 6},
+		{divider.Thresholds{MaxInvalidRatio: 0.4, Window: 10}, divider.TriggerMaxInvalidRatio, 0.5},
+		{divider.Thresholds{MaxInvalidRatio: 0.5, Window: 10}, "", 0},
+		{divider.Thresholds{MaxConsecutiveDivErrors: 1}, "", 0},
+		{divider.Thresholds{MaxInvalid: 1000}, "", 0},
+	}
+
+	for _, test := range tests {
+		summary, err := mocks.NewJobProcessor().
+			SetJobProvider(mocks.NewFakeJobProvider(jobs)).
+			SetThresholds(test.thresholds).
+			Run(context.Background())
+
+		if test.trigger == "" {
+			if err != nil || summary.Threshold != nil || summary.Total != uint64(len(jobs)) {
+				t.Fatalf("%+v: unexpected abort: %v", test.thresholds, err)
+			}
+			continue
+		}
+
+		var terr *divider.ThresholdError
+		if !errors.As(err, &terr) || !errors.Is(err, divider.ErrThresholdExceeded) {
+			t.Fatalf("%+v: expected ThresholdError, got: %v", test.thresholds, err)
+		}
+		if terr.Trigger != test.trigger || terr.Value != test.value || summary.Threshold != terr {
+			t.Fatalf("%+v: unexpected trigger: %+v", test.thresholds, terr)
+		}
+		if summary.Total == uint64(len(jobs)) {
+			t.Fatalf("%+v: processing not stopped", test.thresholds)
+		}
+	}
+
+	// Division errors in a row.
+	for i := range jobs {
+		jobs[i] = &divider.Job{Arg1: i, Arg2: 0, Valid: true}
+	}
+	_, err := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(jobs)).
+		SetThresholds(divider.Thresholds{MaxConsecutiveDivErrors: 3}).
+		Run(context.Background())
+	var terr *divider.ThresholdError
+	if !errors.As(err, &terr) || terr.Trigger != divider.TriggerMaxConsecutiveDivErrs || terr.JobID != 3 {
+		t.Fatalf("expected consecutive division errors threshold, got: %v", err)
+	}
 }
 
 func TestStartUnconfiguredJobProcessor(t *testing.T) {
@@ -72,6 +383,135 @@ func TestJobProcessorProccessedCount(t *testing.T) {
 	}
 }
 
+func TestJobProcessorStats(t *testing.T) {
+
+	proc := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(mocks.AllValid.Jobs))
+
+	if s := proc.Stats(); s.Completed != 0 {
+		t.Fatalf("non-zero stats before start: %+v", s)
+	}
+
+	if err := proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := proc.Stats()
+	if int(s.Completed) != len(mocks.AllValid.Jobs) ||
+		int(s.Latency.Count) != len(mocks.AllValid.Jobs) {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+
+	jobs := append(mocks.ProcessingError.Jobs, mocks.InvalidJob.Jobs...)
+	proc := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(jobs)).
+		SetErrorSamples(2)
+
+	summary, err := proc.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Total != 6 || summary.Valid != 3 ||
+		summary.InvalidInput != 1 || summary.DivErrors != 2 {
+		t.Fatalf("unexpected summary counters: %+v", summary)
+	}
+	if summary.Duration <= 0 || summary.Throughput <= 0 {
+		t.Fatalf("duration and throughput not set: %+v", summary)
+	}
+
+	expected := []divider.ErrorSample{
+		{ID: 1, Kind: divider.KindDivisionError, Error: "Division by zero"},
+		{ID: 3, Kind: divider.KindDivisionError, Error: "Division by zero"},
+	}
+	if len(summary.Errors) != len(expected) {
+		t.Fatalf("expected %d error samples, got: %+v", len(expected), summary.Errors)
+	}
+	for i := range expected {
+		if summary.Errors[i] != expected[i] {
+			t.Fatalf("expected error sample %+v, got: %+v", expected[i], summary.Errors[i])
+		}
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+
+	provider := mocks.NewFakeJobProvider(mocks.AllValid.Jobs)
+	provider.FailEvery(1).FailFn(mocks.StuckFailFn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	summary, err := mocks.NewJobProcessor().SetJobProvider(provider).Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Total >= uint64(len(mocks.AllValid.Jobs)) {
+		t.Fatalf("processing not canceled: %+v", summary)
+	}
+}
+
+// slowDivider divides with a delay.
+type slowDivider time.Duration
+
+func (d slowDivider) Div(a, b int) (int, error) {
+	time.Sleep(time.Duration(d))
+	return mocks.FakeDivider.Div(a, b)
+}
+
+func TestMaxDuration(t *testing.T) {
+
+	jobs := make([]*divider.Job, 1000)
+	for i := range jobs {
+		jobs[i] = mocks.AllValid.Jobs[0]
+	}
+
+	var last divider.Checkpoint
+	summary, err := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(jobs)).
+		SetResultReporter(mocks.NewFakeResultReporter()).
+		SetDivider(slowDivider(time.Millisecond)).
+		SetWorkersCount(1).
+		SetMaxDuration(50*time.Millisecond).
+		SetCheckpoint(0, func(cp divider.Checkpoint) error {
+			last = cp
+			return nil
+		}).
+		Run(context.Background())
+
+	if !errors.Is(err, divider.ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got: %v", err)
+	}
+	if summary.Total == 0 || summary.Total >= uint64(len(jobs)) {
+		t.Fatalf("processing not stopped: %+v", summary)
+	}
+	if summary.NextID != summary.Total || last.NextID != summary.NextID {
+		t.Fatalf("next ID %d of the summary, %d of the checkpoint, %d jobs reported",
+			summary.NextID, last.NextID, summary.Total)
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+
+	reporter := mocks.NewFakeResultReporter()
+	err := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(mocks.AllValid.Jobs)).
+		SetResultReporter(reporter).
+		SetDivider(timeoutdiv.New(slowDivider(time.Second), time.Millisecond)).
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range reporter.Results() {
+		if r.Valid || r.Reason != divider.ReasonTimeout {
+			t.Fatalf("expected timeout, got: %+v", *r)
+		}
+	}
+}
+
 func TestJobProcessorStop(t *testing.T) {
 
 	var stop time.Time
@@ -96,6 +536,287 @@ func TestJobProcessorStop(t *testing.T) {
 	}
 }
 
+func TestReporterError(t *testing.T) {
+
+	reporter := mocks.NewFakeResultReporter()
+	reporter.FailOn(2).FailFn(mocks.TerminalErrorFailFn)
+
+	jobs := make([]*divider.Job, 1000)
+	for i := range jobs {
+		jobs[i] = mocks.AllValid.Jobs[0]
+	}
+
+	proc := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(jobs)).
+		SetResultReporter(reporter).
+		SetWorkersCount(4).
+		SetCloseReporter(true)
+
+	summary, err := proc.Run(context.Background())
+
+	var rerr *divider.ReporterError
+	if !errors.As(err, &rerr) || !errors.Is(err, divider.ErrReporter) {
+		t.Fatalf("expected ReporterError, got: %v", err)
+	}
+	if summary.Total != 2 || len(reporter.Results()) != 2 {
+		t.Fatalf("results reported after the failure: %+v", summary)
+	}
+	if reporter.Flushed() {
+		t.Fatal("failed reporter flushed")
+	}
+	if !reporter.Closed() {
+		t.Fatal("reporter not closed")
+	}
+}
+
+func TestMultiReporter(t *testing.T) {
+
+	primary, broken := mocks.NewFakeResultReporter(), mocks.NewFakeResultReporter()
+	broken.FailOn(2).FailFn(mocks.TerminalErrorFailFn)
+
+	multi := divider.NewMultiReporter().
+		Add("primary", primary, divider.FailRun).
+		Add("broken", broken, divider.DisableOnError)
+	proc := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(mocks.AllValid.Jobs)).
+		SetResultReporter(multi).
+		SetCloseReporter(true)
+
+	if err := proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	mocks.Validate(t, mocks.AllValid, primary.Results())
+	if len(broken.Results()) != 2 || multi.Disabled()["broken"] == nil {
+		t.Fatalf("broken reporter not disabled: %v", multi.Disabled())
+	}
+	if !primary.Flushed() || broken.Flushed() || !primary.Closed() || !broken.Closed() {
+		t.Fatal("reporters must be closed, but only enabled ones flushed")
+	}
+	if sizes := multi.Sizes(); len(sizes) != 2 || sizes[0] != int64(len(mocks.AllValid.Jobs)) {
+		t.Fatalf("unexpected sizes: %v", sizes)
+	}
+
+	// The same failure with FailRun policy fails the run.
+	broken = mocks.NewFakeResultReporter()
+	broken.FailOn(2).FailFn(mocks.TerminalErrorFailFn)
+	proc.SetJobProvider(mocks.NewFakeJobProvider(mocks.AllValid.Jobs)).
+		SetResultReporter(divider.NewMultiReporter().
+			Add("primary", mocks.NewFakeResultReporter(), divider.DisableOnError).
+			Add("broken", broken, divider.FailRun))
+	if err := proc.Start(); !errors.Is(err, divider.ErrReporter) {
+		t.Fatalf("expected ReporterError, got: %v", err)
+	}
+
+	// Run fails if all the reporters are disabled.
+	broken = mocks.NewFakeResultReporter()
+	broken.FailOn(2).FailFn(mocks.TerminalErrorFailFn)
+	proc.SetJobProvider(mocks.NewFakeJobProvider(mocks.AllValid.Jobs)).
+		SetResultReporter(divider.NewMultiReporter().
+			Add("broken", broken, divider.DisableOnError))
+	if err := proc.Start(); !errors.Is(err, divider.ErrReporter) {
+		t.Fatalf("expected ReporterError, got: %v", err)
+	}
+}
+
+func TestIDPolicy(t *testing.T) {
+
+	input := `[{"id": "a", "arg1": 1, "arg2": 1}, {"id": 7, "arg1": 2, "arg2": 1},
+		{"arg1": 3, "arg2": 1}, {"id": "a", "arg1": 4, "arg2": 1}, {"id": "b", "arg1": 5}]`
+
+	tests := []struct {
+		policy divider.IDPolicy
+		ids    []string
+		valid  []bool
+	}{
+		{divider.IDKeep, []string{"a", "7", "", "a", "b"}, []bool{true, true, true, true, false}},
+		{divider.IDAutoAssign, []string{"a", "7", "", "", "b"}, []bool{true, true, true, true, false}},
+		{divider.IDWarn, []string{"a", "7", "", "a", "b"}, []bool{true, true, true, true, false}},
+		{divider.IDReject, []string{"a", "7", "", "a", "b"}, []bool{true, true, false, false, false}},
+	}
+
+	for _, test := range tests {
+		provider, _ := jsonprov.New(strings.NewReader(input))
+		reporter := mocks.NewFakeResultReporter()
+		err := mocks.NewJobProcessor().
+			SetJobProvider(provider).
+			SetResultReporter(reporter).
+			SetIDPolicy(test.policy).
+			Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, r := range reporter.Results() {
+			if r.InputID != test.ids[i] || r.Valid != test.valid[i] {
+				t.Fatalf("policy %d, job %d: expected %q %v, got %+v",
+					test.policy, i, test.ids[i], test.valid[i], *r)
+			}
+		}
+		if test.policy == divider.IDReject {
+			results := reporter.Results()
+			if results[2].Reason != divider.ReasonIDMissing || results[3].Reason != divider.ReasonDuplicateID {
+				t.Fatalf("unexpected reasons: %s, %s", results[2].Reason, results[3].Reason)
+			}
+		}
+	}
+}
+
+func TestShard(t *testing.T) {
+
+	input := `[{"id": "a", "arg1": 1, "arg2": 1}, {"id": "b", "arg1": 2, "arg2": 1},
+		{"id": "c", "arg1": 3}, {"id": "d", "arg1": 4, "arg2": 1}, {"arg1": 5, "arg2": 1},
+		{"id": "f", "arg1": 6, "arg2": 0}, {"id": "g", "arg1": 7, "arg2": 1}]`
+
+	for _, byInputID := range []bool{false, true} {
+		seen := make(map[uint64]bool)
+		for i := uint64(0); i < 3; i++ {
+			provider, _ := jsonprov.New(strings.NewReader(input))
+			reporter := mocks.NewFakeResultReporter()
+			summary, err := mocks.NewJobProcessor().
+				SetJobProvider(provider).
+				SetResultReporter(reporter).
+				SetShard(divider.Shard{Index: i, Count: 3, ByInputID: byInputID}).
+				Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Total != uint64(len(reporter.Results())) || summary.NextID != 7 {
+				t.Fatalf("unexpected summary: %+v", summary)
+			}
+			for _, r := range reporter.Results() {
+				if seen[r.ID] || (!byInputID && r.ID%3 != i) || (r.Valid && r.Value != int(r.ID)+1) {
+					t.Fatalf("shard %d: unexpected result %+v", i, *r)
+				}
+				seen[r.ID] = true
+			}
+		}
+		if len(seen) != 7 {
+			t.Fatalf("expected 7 results in all shards, got %d", len(seen))
+		}
+	}
+
+	if s, err := divider.ParseShard("1/3"); err != nil || s.Index != 1 || s.Count != 3 {
+		t.Fatalf("unexpected shard %v: %v", s, err)
+	}
+	for _, s := range []string{"3/3", "0/0", "1", "a/b", "1/2/3", "1/2x", "0/3 junk", " 0/3", "-1/3"} {
+		if _, err := divider.ParseShard(s); err == nil {
+			t.Fatalf("shard %q must be invalid", s)
+		}
+	}
+}
+
+func TestErrorCategories(t *testing.T) {
+
+	if err := divider.NewJobProcessor().Start(); !errors.Is(err, divider.ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got: %v", err)
+	}
+
+	cause := errors.New("cause")
+	err := fmt.Errorf("job: %w", divider.NewNonTerminalError(cause))
+	if !errors.Is(err, divider.ErrInvalidInput) || !errors.Is(err, cause) {
+		t.Fatalf("NonTerminalError must be of ErrInvalidInput category and wrap the cause")
+	}
+	if errors.Is(divider.ErrArg1Missing, divider.ErrArg2Missing) {
+		t.Fatal("missing argument errors must differ")
+	}
+
+	// Wrapped NonTerminalError does not stop processing.
+	provider := mocks.NewFakeJobProvider(mocks.AllValid.Jobs)
+	provider.FailOn(1).FailFn(mocks.NewErrFailFn(err))
+	mocks.ProviderNonTerminalErrorTest(t, provider)
+}
+
+func TestReporterFlush(t *testing.T) {
+
+	reporter := mocks.NewFakeResultReporter()
+	proc := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(mocks.AllValid.Jobs)).
+		SetResultReporter(reporter)
+
+	if err := proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if !reporter.Flushed() || reporter.Closed() {
+		t.Fatal("reporter must be flushed, but not closed by default")
+	}
+}
+
+// cancelingReporter cancels the run after n results reported.
+type cancelingReporter struct {
+	*mocks.FakeResultReporter
+	n      int
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReporter) Report(result *divider.JobResult) error {
+	if len(r.Results()) == r.n {
+		r.cancel()
+	}
+	return r.FakeResultReporter.Report(result)
+}
+
+func TestCheckpointResume(t *testing.T) {
+
+	jobs := make([]*divider.Job, 1000)
+	for i := range jobs {
+		jobs[i] = &divider.Job{Arg1: i, Arg2: 1, Valid: true}
+	}
+
+	for _, chunk := range []uint{1, 16} {
+		ctx, cancel := context.WithCancel(context.Background())
+		first := &cancelingReporter{mocks.NewFakeResultReporter(), 300, cancel}
+
+		var cp divider.Checkpoint
+		_, err := mocks.NewJobProcessor().
+			SetJobProvider(mocks.NewFakeJobProvider(jobs)).
+			SetResultReporter(first).
+			SetWorkersCount(8).
+			SetChunkSize(chunk).
+			SetCheckpoint(time.Millisecond, func(c divider.Checkpoint) error {
+				cp = c
+				return nil
+			}).
+			Run(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cp.NextID == 0 || cp.NextID >= uint64(len(jobs)) {
+			t.Fatalf("chunk %d: unexpected checkpoint: %+v", chunk, cp)
+		}
+
+		// Results reported after the checkpoint are lost, as if the
+		// process was killed.
+		results := first.Results()[:cp.Output]
+
+		provider := mocks.NewFakeJobProvider(jobs)
+		provider.SetOffset(cp.Offset)
+		second := mocks.NewFakeResultReporter()
+		_, err = mocks.NewJobProcessor().
+			SetJobProvider(provider).
+			SetResultReporter(second).
+			SetChunkSize(chunk).
+			SetResume(cp).
+			Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen := make(map[uint64]bool)
+		for _, r := range append(results, second.Results()...) {
+			if seen[r.ID] {
+				t.Fatalf("chunk %d: job %d reported twice", chunk, r.ID)
+			}
+			if r.Value != jobs[r.ID].Arg1 {
+				t.Fatalf("chunk %d: job %d: unexpected value %d", chunk, r.ID, r.Value)
+			}
+			seen[r.ID] = true
+		}
+		if len(seen) != len(jobs) {
+			t.Fatalf("chunk %d: %d jobs reported, expected %d", chunk, len(seen), len(jobs))
+		}
+	}
+}
+
 func TestProviderNonTerminalError(t *testing.T) {
 	provider := mocks.NewFakeJobProvider(mocks.AllValid.Jobs)
 	provider.FailOn(1).FailFn(mocks.NonTerminalErrorFailFn)
@@ -107,3 +828,70 @@ func TestProviderTerminalError(t *testing.T) {
 	provider.FailOn(1).FailFn(mocks.TerminalErrorFailFn)
 	mocks.ProviderTerminalErrorTest(t, provider)
 }
+
+// BenchmarkJobProcessor measures throughput of the whole pipeline over
+// a file of a million jobs with and without chunked dispatch.
+func BenchmarkJobProcessor(b *testing.B) {
+
+	path := filepath.Join(b.TempDir(), "jobs.json")
+	if err := writeJobsFile(path, benchJobs); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, chunk := range []uint{1, 64, 512} {
+		b.Run(fmt.Sprintf("Chunk=%d", chunk), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := processJobsFile(path, chunk); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(benchJobs*b.N)/b.Elapsed().Seconds(), "jobs/s")
+		})
+	}
+}
+
+func processJobsFile(path string, chunk uint) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	provider, err := jsonprov.New(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	reporter, err := csvrep.New(mocks.NewFakeWriter())
+	if err != nil {
+		return err
+	}
+
+	return mocks.NewJobProcessor().
+		SetJobProvider(provider).
+		SetResultReporter(reporter).
+		SetWorkersCount(uint(runtime.NumCPU())).
+		SetChunkSize(chunk).
+		Start()
+}
+
+func writeJobsFile(path string, n int) error {
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.WriteString("[\n")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			w.WriteString(",\n")
+		}
+		fmt.Fprintf(w, `{"arg1": %d, "arg2": %d}`, i, i%100)
+	}
+	w.WriteString("\n]\n")
+
+	return w.Flush()
+}
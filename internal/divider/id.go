@@ -0,0 +1,65 @@
+package divider
+
+import (
+	"errors"
+	"fmt"
+)
+
+// IDPolicy tells JobProcessor what to do with jobs whose input ID is missing
+// or duplicates the ID of a previous job. Results without input ID fall back
+// to the sequential ID in reporters.
+type IDPolicy int
+
+const (
+	// IDKeep takes input IDs as they are without any checks.
+	IDKeep IDPolicy = iota
+	// IDAutoAssign drops duplicate input IDs, so that the sequential ID is
+	// used instead.
+	IDAutoAssign
+	// IDWarn logs missing and duplicate input IDs and keeps them.
+	IDWarn
+	// IDReject rejects jobs with missing or duplicate input IDs with
+	// ReasonIDMissing or ReasonDuplicateID.
+	IDReject
+)
+
+// StageID is the name of the stage in StageError of jobs rejected by
+// IDReject policy.
+const StageID = "id"
+
+var errIDMissing = errors.New("job ID missing")
+
+// checkID applies the ID policy to the job. It returns an error if the job
+// must be rejected. With any policy but IDKeep the IDs of all the jobs are
+// kept in memory. They are not checkpointed, so such runs can not be resumed
+// without missing duplicates.
+func (p *JobProcessor) checkID(task *workerTask) error {
+	id := task.job.InputID
+	task.result.InputID = id
+	if p.idPolicy == IDKeep {
+		return nil
+	}
+
+	var err error
+	if len(id) == 0 {
+		if p.idPolicy == IDAutoAssign {
+			return nil
+		}
+		err = &ReasonError{Code: ReasonIDMissing, Err: errIDMissing}
+	} else if p.ids[id] {
+		err = &ReasonError{Code: ReasonDuplicateID, Err: fmt.Errorf("duplicate job ID %q", id)}
+	} else {
+		p.ids[id] = true
+		return nil
+	}
+
+	switch p.idPolicy {
+	case IDAutoAssign:
+		task.result.InputID = ""
+	case IDWarn:
+		p.log.Warn("bad job ID", "job_id", task.result.ID, "input_id", id, "error", err)
+	case IDReject:
+		return &StageError{Stage: StageID, Err: err}
+	}
+	return nil
+}
@@ -1,10 +1,12 @@
 package jsonprov
 
 import (
+	"bufio"
 	"encoding/json"
-	"errors"
 	"io"
+	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/b-2019-apt-test/divider/internal/divider"
 )
@@ -12,54 +14,257 @@ import (
 // JSONJobDecoder implements divider.JobProvider. As implied, JSONJobDecoder
 // decodes JSON stream of job objects.
 type JSONJobDecoder struct {
-	dec *json.Decoder
+	dec     *json.Decoder
+	base    int64
+	keepRaw bool
+
+	// src is the input, start is its initial position. They are used to
+	// locate syntax errors.
+	src   io.Reader
+	start int64
+}
+
+// SyntaxError describes malformed JSON input. It is a terminal error of
+// divider.ErrMalformedInput category. Offset follows the offending byte.
+// Line and Column of the byte start from 1 and are only known if the input
+// implements io.Seeker, otherwise they are zero.
+type SyntaxError struct {
+	Offset int64
+	Line   int64
+	Column int64
+	Err    error
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Line == 0 {
+		return "JSON syntax error at offset " +
+			strconv.FormatInt(e.Offset, 10) + ": " + e.Err.Error()
+	}
+	return "JSON syntax error at line " + strconv.FormatInt(e.Line, 10) +
+		", column " + strconv.FormatInt(e.Column, 10) +
+		" (offset " + strconv.FormatInt(e.Offset, 10) + "): " + e.Err.Error()
+}
+
+// Unwrap returns the error of the JSON decoder.
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is divider.ErrMalformedInput.
+func (e *SyntaxError) Is(target error) bool {
+	return target == divider.ErrMalformedInput
 }
 
 // New creates a new JSONJobDecoder with io.Reader.
 func New(r io.Reader) (*JSONJobDecoder, error) {
-	d := &JSONJobDecoder{dec: json.NewDecoder(r)}
+	d := &JSONJobDecoder{dec: json.NewDecoder(r), src: r}
+	if s, ok := r.(io.Seeker); ok {
+		d.start, _ = s.Seek(0, io.SeekCurrent)
+	}
 	if _, err := d.dec.Token(); err != nil && err == io.EOF {
 		return nil, err
 	}
 	return d, nil
 }
 
+// NewAt creates a new JSONJobDecoder which continues decoding of the job
+// array at the offset previously returned by Offset. Offset 0 means the
+// beginning of the input.
+func NewAt(r io.ReadSeeker, offset int64) (*JSONJobDecoder, error) {
+	if offset == 0 {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return New(r)
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// The rest of the array is either ", {...}, ..." or "]". Skip the
+	// separator and decode the rest as a new array.
+	br := bufio.NewReader(r)
+	skipped, comma := int64(0), false
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == ',' && !comma {
+			comma = true
+		} else if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			br.UnreadByte()
+			break
+		}
+		skipped++
+	}
+
+	d := &JSONJobDecoder{
+		dec:  json.NewDecoder(io.MultiReader(strings.NewReader("["), br)),
+		base: offset + skipped - 1,
+		src:  r,
+	}
+	if _, err := d.dec.Token(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Offset returns the input offset where the next job starts, to be used
+// with NewAt.
+func (d *JSONJobDecoder) Offset() int64 {
+	return d.base + d.dec.InputOffset()
+}
+
 // More reports whether there is another element in the current array or object
 // being parsed.
 func (d *JSONJobDecoder) More() bool {
 	return d.dec.More()
 }
 
+// SetKeepRaw makes the decoder keep the raw JSON of every job in Job.Raw,
+// which is needed to record dead letters with the original input.
+func (d *JSONJobDecoder) SetKeepRaw(keep bool) *JSONJobDecoder {
+	d.keepRaw = keep
+	return d
+}
+
 // Next reads the next JSON-encoded job from its input.
 func (d *JSONJobDecoder) Next(job *divider.Job) (err error) {
 
-	nilJob := &struct{ Arg1, Arg2 *int }{}
+	if d.keepRaw {
+		var raw json.RawMessage
+		if err = d.dec.Decode(&raw); err != nil {
+			return d.decodeErr(err)
+		}
+		return Unmarshal(raw, job)
+	}
+
+	nilJob := &nilJob{}
 	if err = d.dec.Decode(nilJob); err != nil {
-		switch typedErr := err.(type) {
-		case *json.UnmarshalTypeError:
-			return divider.NewNonTerminalError(err)
-		case *json.SyntaxError:
-			return newJSONSyntaxErrorWithOffset(typedErr)
+		return d.decodeErr(err)
+	}
+	return nilJob.fill(job)
+}
+
+// Skip implements divider.Skipper. It skips the next JSON value without
+// checking it is a valid job.
+func (d *JSONJobDecoder) Skip() error {
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return d.decodeErr(err)
+	}
+	return nil
+}
+
+// Unmarshal parses a single JSON-encoded job. It returns the same errors as
+// JSONJobDecoder.Next does for invalid jobs. Raw member of the job is set
+// to data.
+func Unmarshal(data []byte, job *divider.Job) error {
+	job.Raw = data
+	nilJob := &nilJob{}
+	if err := json.Unmarshal(data, nilJob); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return newTypeMismatchError(err)
 		}
-		return
+		return err
 	}
+	return nilJob.fill(job)
+}
 
-	if nilJob.Arg1 == nil {
+type nilJob struct {
+	Arg1, Arg2 *int
+	ID         jobID
+}
+
+func (j *nilJob) fill(job *divider.Job) error {
+
+	job.InputID = string(j.ID)
+
+	if j.Arg1 == nil {
 		return divider.ErrArg1Missing
 	}
-	if nilJob.Arg2 == nil {
+	if j.Arg2 == nil {
 		return divider.ErrArg2Missing
 	}
 
-	job.Arg1, job.Arg2 = *nilJob.Arg1, *nilJob.Arg2
+	job.Arg1, job.Arg2 = *j.Arg1, *j.Arg2
 	job.Valid = true
 
 	return nil
 }
 
-// newJSONSyntaxErrorWithOffset is convenience wrapper for json.SyntaxError
-// that also provides the syntax error offset.
-func newJSONSyntaxErrorWithOffset(e *json.SyntaxError) error {
-	return errors.New("JSON syntax error at offset " +
-		strconv.FormatInt(e.Offset, 10) + ": " + e.Error())
+// jobID is the optional id member of a job, a string or an integer.
+type jobID string
+
+func (id *jobID) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	if b[0] == '"' {
+		return json.Unmarshal(b, (*string)(id))
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	// IDs are only carried through, so integers of any size are kept as
+	// they are written.
+	if strings.ContainsAny(string(n), ".eE") {
+		return &json.UnmarshalTypeError{Value: "number " + string(n), Type: reflect.TypeOf(""), Field: "id"}
+	}
+	*id = jobID(n)
+	return nil
+}
+
+func (d *JSONJobDecoder) decodeErr(err error) error {
+	switch typedErr := err.(type) {
+	case *json.UnmarshalTypeError:
+		return newTypeMismatchError(err)
+	case *json.SyntaxError:
+		return d.syntaxError(d.base+typedErr.Offset, err)
+	}
+	if err == io.ErrUnexpectedEOF {
+		return d.syntaxError(d.Offset(), err)
+	}
+	return err
+}
+
+func newTypeMismatchError(err error) error {
+	return divider.NewNonTerminalError(
+		&divider.ReasonError{Code: divider.ReasonTypeMismatch, Err: err})
+}
+
+// syntaxError creates SyntaxError at the offset, which follows the offending
+// byte as in json.SyntaxError. The line and column of the byte are found by
+// reading the input from the start again, which is fine as the error
+// terminates decoding anyway.
+func (d *JSONJobDecoder) syntaxError(offset int64, err error) error {
+	e := &SyntaxError{Offset: offset, Err: err}
+	rs, ok := d.src.(io.ReadSeeker)
+	if !ok {
+		return e
+	}
+	if _, serr := rs.Seek(d.start, io.SeekStart); serr != nil {
+		return e
+	}
+
+	if offset > 0 {
+		offset--
+	}
+	br := bufio.NewReader(io.LimitReader(rs, offset))
+	line, column := int64(1), int64(1)
+	for {
+		c, rerr := br.ReadByte()
+		if rerr != nil {
+			break
+		}
+		if c == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	e.Line, e.Column = line, column
+	return e
 }
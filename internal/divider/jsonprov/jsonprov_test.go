@@ -2,9 +2,12 @@ package jsonprov_test
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"strings"
 	"testing"
 
+	"github.com/b-2019-apt-test/divider/internal/divider"
 	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
 	"github.com/b-2019-apt-test/divider/internal/divider/mocks"
 )
@@ -14,6 +17,9 @@ var nonTerminalCases = []string{
 	`[{ "arg1": "" }]`,
 	`[{ "arg1": 1 }]`,
 	`[{ "arg2": 1 }]`,
+	`[{ "arg1": 1, "arg2": 1, "id": 1.5 }]`,
+	`[{ "arg1": 1, "arg2": 1, "id": 1e3 }]`,
+	`[{ "arg1": 1, "arg2": 1, "id": {} }]`,
 }
 
 var terminalCases = []string{
@@ -32,6 +38,66 @@ func TestNewErr(t *testing.T) {
 	}
 }
 
+func TestNewAt(t *testing.T) {
+
+	input := "[ {\"arg1\": 1, \"arg2\": 1},\n{\"arg1\": 2, \"arg2\": 1} ,{\"arg1\": 3, \"arg2\": 1}\n]"
+
+	offsets := []int64{0}
+	provider := newProvider(input)
+	for provider.More() {
+		if err := provider.Next(&divider.Job{}); err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, provider.Offset())
+	}
+
+	for i, offset := range offsets {
+		provider, err := jsonprov.NewAt(strings.NewReader(input), offset)
+		if err != nil {
+			t.Fatalf("offset %d: %v", offset, err)
+		}
+		for arg1 := i + 1; arg1 <= 3; arg1++ {
+			var job divider.Job
+			if !provider.More() {
+				t.Fatalf("offset %d: job %d missing", offset, arg1)
+			}
+			if err := provider.Next(&job); err != nil {
+				t.Fatalf("offset %d: %v", offset, err)
+			}
+			if job.Arg1 != arg1 {
+				t.Fatalf("offset %d: expected arg1 %d, got %d", offset, arg1, job.Arg1)
+			}
+			if next := provider.Offset(); next != offsets[arg1] {
+				t.Fatalf("offset %d: expected next offset %d, got %d", offset, offsets[arg1], next)
+			}
+		}
+		if provider.More() {
+			t.Fatalf("offset %d: unexpected job", offset)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+
+	input := "[\n {\"arg1\": 1,\n  \"arg2\": }]"
+	provider := newProvider(input)
+	err := provider.Next(&divider.Job{})
+
+	var serr *jsonprov.SyntaxError
+	if !errors.As(err, &serr) || !errors.Is(err, divider.ErrMalformedInput) {
+		t.Fatalf("expected syntax error, got: %v", err)
+	}
+	if serr.Offset != 26 || serr.Line != 3 || serr.Column != 11 {
+		t.Fatalf("unexpected location: %v", serr)
+	}
+
+	// Without io.Seeker the location is the offset only.
+	provider, _ = jsonprov.New(io.MultiReader(strings.NewReader(input)))
+	if err = provider.Next(&divider.Job{}); !errors.As(err, &serr) || serr.Line != 0 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 func TestTerminalCases(t *testing.T) {
 	for _, badInput := range terminalCases {
 		mocks.ProviderTerminalErrorTest(t, newProvider(badInput))
@@ -62,3 +128,19 @@ func TestCases(t *testing.T) {
 		mocks.Validate(t, test, reporter.Results())
 	})
 }
+
+func TestInputID(t *testing.T) {
+
+	provider := newProvider(`[{"id": "a-1", "arg1": 1, "arg2": 1}, {"id": 42, "arg1": 1},
+		{"id": null, "arg1": 1, "arg2": 1}, {"arg1": 1, "arg2": 1},
+		{"id": 18446744073709551615, "arg1": 1, "arg2": 1}, {"id": -99999999999999999999, "arg1": 1, "arg2": 1}]`)
+	expected := []string{"a-1", "42", "", "", "18446744073709551615", "-99999999999999999999"}
+
+	for i, id := range expected {
+		var job divider.Job
+		provider.Next(&job)
+		if job.InputID != id {
+			t.Fatalf("job %d: expected ID %q, got %q", i, id, job.InputID)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Package jsonrep reports processing results as newline-delimited JSON.
+package jsonrep
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+)
+
+// JSONResultReporter writes every result as a JSON object on a line of its
+// own:
+//
+//	{"id":1,"value":0,"valid":false,"reason":"div_zero"}
+//
+// Reason is omitted for valid results. Source and index members are added
+// if the source of the job is known, input_id if the job has it. Lines reach
+// the writer on Flush.
+type JSONResultReporter struct {
+	w    *bufio.Writer
+	buf  []byte
+	size int64
+}
+
+// New creates a new JSONResultReporter.
+func New(w io.Writer) *JSONResultReporter {
+	return &JSONResultReporter{w: bufio.NewWriter(w)}
+}
+
+// Resume creates a new JSONResultReporter which appends results to the
+// output of size bytes written previously by another JSONResultReporter.
+func Resume(w io.Writer, size int64) *JSONResultReporter {
+	r := New(w)
+	r.size = size
+	return r
+}
+
+// Report writes a JSON line for the job result.
+func (r *JSONResultReporter) Report(result *divider.JobResult) error {
+	r.buf = append(r.buf[:0], `{"id":`...)
+	r.buf = strconv.AppendUint(r.buf, result.ID, 10)
+	r.buf = append(r.buf, `,"value":`...)
+	r.buf = strconv.AppendInt(r.buf, int64(result.Value), 10)
+	r.buf = append(r.buf, `,"valid":`...)
+	r.buf = strconv.AppendBool(r.buf, result.Valid)
+	if len(result.Reason) != 0 {
+		r.buf = append(r.buf, `,"reason":`...)
+		r.buf = appendString(r.buf, string(result.Reason))
+	}
+	if len(result.Source) != 0 {
+		r.buf = append(r.buf, `,"source":`...)
+		r.buf = appendString(r.buf, result.Source)
+		r.buf = append(r.buf, `,"index":`...)
+		r.buf = strconv.AppendUint(r.buf, result.Index, 10)
+	}
+	if len(result.InputID) != 0 {
+		r.buf = append(r.buf, `,"input_id":`...)
+		r.buf = appendString(r.buf, result.InputID)
+	}
+	r.buf = append(r.buf, "}\n"...)
+	n, err := r.w.Write(r.buf)
+	r.size += int64(n)
+	return err
+}
+
+// appendString appends s as a JSON string.
+func appendString(buf []byte, s string) []byte {
+	b, _ := json.Marshal(s)
+	return append(buf, b...)
+}
+
+// Size returns the number of bytes written including buffered ones.
+func (r *JSONResultReporter) Size() int64 {
+	return r.size
+}
+
+// Flush writes buffered results to the underlying writer.
+func (r *JSONResultReporter) Flush() error {
+	return r.w.Flush()
+}
@@ -0,0 +1,61 @@
+package jsonrep_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonrep"
+	"github.com/b-2019-apt-test/divider/internal/divider/mocks"
+)
+
+func TestCases(t *testing.T) {
+	mocks.RunTestCases(t, func(t *testing.T, test mocks.TestCase) {
+
+		var buf bytes.Buffer
+		proc := mocks.NewJobProcessor().
+			SetJobProvider(mocks.NewFakeJobProvider(test.Jobs)).
+			SetResultReporter(jsonrep.New(&buf))
+
+		if err := proc.Start(); err != test.Err {
+			t.Fatalf("expected err %v, got: %v", test.Err, err)
+		}
+
+		var results []*divider.JobResult
+		s := bufio.NewScanner(&buf)
+		for s.Scan() {
+			var r struct {
+				ID     uint64
+				Value  int
+				Valid  bool
+				Reason divider.Reason
+			}
+			if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+				t.Fatalf("failed to parse %s: %v", s.Bytes(), err)
+			}
+			if r.Valid == (len(r.Reason) != 0) {
+				t.Fatalf("reason must be set for invalid results only: %s", s.Bytes())
+			}
+			results = append(results, &divider.JobResult{ID: r.ID, Value: r.Value, Valid: r.Valid})
+		}
+
+		mocks.Validate(t, test, results)
+	})
+}
+
+func TestSize(t *testing.T) {
+	var buf bytes.Buffer
+	r := jsonrep.Resume(&buf, 10)
+	if err := r.Report(&divider.JobResult{ID: 1, Value: 2, Valid: true}); err != nil {
+		t.Fatal(err)
+	}
+	r.Flush()
+
+	expected := `{"id":1,"value":2,"valid":true}` + "\n"
+	if buf.String() != expected || r.Size() != int64(10+len(expected)) {
+		t.Fatalf("expected %q of size %d, got %q of size %d",
+			expected, 10+len(expected), buf.String(), r.Size())
+	}
+}
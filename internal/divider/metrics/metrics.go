@@ -0,0 +1,265 @@
+// Package metrics collects metrics of divider.JobProcessor and exposes them
+// in Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/pkg/atomicfile"
+	"github.com/b-2019-apt-test/divider/pkg/pool"
+)
+
+// Metrics implements divider.Observer and counts processing events. It is
+// safe for concurrent use.
+type Metrics struct {
+	method string
+	stats  func() pool.Stats
+
+	read     atomic.Uint64
+	divided  atomic.Uint64
+	reported atomic.Uint64
+	report   histogram
+
+	mu       sync.Mutex
+	rejected map[divider.Reason]uint64
+	failed   map[divider.Reason]uint64
+}
+
+// New creates a new Metrics for jobs divided by the method.
+func New(method string) *Metrics {
+	return &Metrics{
+		method:   method,
+		report:   newHistogram(pool.LatencyBuckets),
+		rejected: make(map[divider.Reason]uint64),
+		failed:   make(map[divider.Reason]uint64),
+	}
+}
+
+// SetStats specifies the source of the worker pool gauges, such as
+// divider.JobProcessor.Stats.
+func (m *Metrics) SetStats(stats func() pool.Stats) *Metrics {
+	m.stats = stats
+	return m
+}
+
+// RunStarted implements divider.Observer.
+func (m *Metrics) RunStarted(divider.RunEvent) {}
+
+// RunEnded implements divider.Observer.
+func (m *Metrics) RunEnded(divider.RunEvent) {}
+
+// JobRead implements divider.Observer.
+func (m *Metrics) JobRead(divider.JobEvent) {
+	m.read.Add(1)
+}
+
+// JobRejected implements divider.Observer. Jobs rejected by stages are
+// counted as read already.
+func (m *Metrics) JobRejected(e divider.JobEvent) {
+	var serr *divider.StageError
+	if !errors.As(e.Err, &serr) {
+		m.read.Add(1)
+	}
+	m.mu.Lock()
+	m.rejected[e.Reason]++
+	m.mu.Unlock()
+}
+
+// JobDivided implements divider.Observer.
+func (m *Metrics) JobDivided(e divider.JobEvent) {
+	m.divided.Add(1)
+	if e.Err != nil {
+		m.mu.Lock()
+		m.failed[e.Reason]++
+		m.mu.Unlock()
+	}
+}
+
+// ResultReported implements divider.Observer.
+func (m *Metrics) ResultReported(e divider.JobEvent) {
+	m.reported.Add(1)
+	m.report.observe(e.Duration)
+}
+
+// WriteTo writes metrics in Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+
+	mw := &writer{w: bufio.NewWriter(w)}
+	method := label{"method", m.method}
+
+	mw.counter("divider_jobs_read_total", "Jobs read from the input, including rejected ones.",
+		sample{value: m.read.Load()})
+
+	m.mu.Lock()
+	rejected := reasonSamples(m.rejected)
+	failed := reasonSamples(m.failed, method)
+	m.mu.Unlock()
+
+	mw.counter("divider_jobs_rejected_total", "Jobs rejected as invalid input.", rejected...)
+	mw.counter("divider_jobs_divided_total", "Jobs passed to the division method.",
+		sample{labels: []label{method}, value: m.divided.Load()})
+	mw.counter("divider_division_errors_total", "Jobs failed on division.", failed...)
+	mw.counter("divider_results_reported_total", "Results written by the reporter.",
+		sample{value: m.reported.Load()})
+	mw.histogram("divider_report_duration_seconds", "Time taken to write a result.",
+		m.report.snapshot())
+
+	if m.stats != nil {
+		s := m.stats()
+		mw.gauge("divider_pool_queued", "Jobs waiting for workers.", s.Queued)
+		mw.gauge("divider_pool_in_flight", "Jobs taken by workers, which results are not delivered yet.", s.InFlight)
+		mw.gauge("divider_pool_idle_workers", "Workers waiting for jobs.", s.IdleWorkers)
+		mw.histogram("divider_pool_job_duration_seconds", "Time taken by workers to process a job.", s.Latency)
+	}
+
+	if mw.err == nil {
+		mw.err = mw.w.Flush()
+	}
+	return mw.n, mw.err
+}
+
+// ServeHTTP writes metrics as the response.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteFile writes metrics to the file for the textfile collector of node
+// exporter. The file is replaced atomically, so the collector never reads a
+// partially written file.
+func (m *Metrics) WriteFile(path string) error {
+	return atomicfile.Write(path, func(w io.Writer) error {
+		_, err := m.WriteTo(w)
+		return err
+	})
+}
+
+func reasonSamples(counts map[divider.Reason]uint64, labels ...label) []sample {
+	samples := make([]sample, 0, len(counts))
+	for reason, n := range counts {
+		l := append(labels[:len(labels):len(labels)], label{"reason", string(reason)})
+		samples = append(samples, sample{labels: l, value: n})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].labels[len(labels)].value < samples[j].labels[len(labels)].value
+	})
+	return samples
+}
+
+// histogram records durations into buckets with atomic counters.
+type histogram struct {
+	bounds []time.Duration
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Int64
+}
+
+func newHistogram(bounds []time.Duration) histogram {
+	return histogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *histogram) snapshot() pool.Histogram {
+	s := pool.Histogram{
+		Bounds: h.bounds,
+		Counts: make([]uint64, len(h.counts)),
+		Count:  h.count.Load(),
+		Sum:    time.Duration(h.sum.Load()),
+	}
+	for i := range h.counts {
+		s.Counts[i] = h.counts[i].Load()
+	}
+	return s
+}
+
+type label struct {
+	name, value string
+}
+
+type sample struct {
+	labels []label
+	value  uint64
+}
+
+// writer formats metric families and keeps the first error.
+type writer struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *writer) write(s string) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.WriteString(s)
+	w.n += int64(n)
+	w.err = err
+}
+
+func (w *writer) header(name, help, typ string) {
+	w.write("# HELP " + name + " " + help + "\n# TYPE " + name + " " + typ + "\n")
+}
+
+func (w *writer) counter(name, help string, samples ...sample) {
+	w.header(name, help, "counter")
+	for _, s := range samples {
+		w.write(name + formatLabels(s.labels) + " " + strconv.FormatUint(s.value, 10) + "\n")
+	}
+}
+
+func (w *writer) gauge(name, help string, value uint64) {
+	w.header(name, help, "gauge")
+	w.write(name + " " + strconv.FormatUint(value, 10) + "\n")
+}
+
+func (w *writer) histogram(name, help string, h pool.Histogram) {
+	w.header(name, help, "histogram")
+	var n uint64
+	for i, b := range h.Bounds {
+		n += h.Counts[i]
+		le := strconv.FormatFloat(b.Seconds(), 'g', -1, 64)
+		w.write(name + `_bucket{le="` + le + `"} ` + strconv.FormatUint(n, 10) + "\n")
+	}
+	// Count is taken from buckets, as the snapshot is not atomic.
+	if len(h.Counts) > len(h.Bounds) {
+		n += h.Counts[len(h.Bounds)]
+	}
+	w.write(name + `_bucket{le="+Inf"} ` + strconv.FormatUint(n, 10) + "\n")
+	w.write(name + "_sum " + strconv.FormatFloat(h.Sum.Seconds(), 'g', -1, 64) + "\n")
+	w.write(name + "_count " + strconv.FormatUint(n, 10) + "\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels []label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.name + `="` + labelEscaper.Replace(l.value) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
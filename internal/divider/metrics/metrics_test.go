@@ -0,0 +1,80 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
+	"github.com/b-2019-apt-test/divider/internal/divider/metrics"
+	"github.com/b-2019-apt-test/divider/internal/divider/mocks"
+)
+
+const input = `[{"arg1": 1}, {"arg1": "x", "arg2": 1}, {"arg1": 1, "arg2": 0},
+	{"arg1": 4, "arg2": 2}, {"arg1": 6, "arg2": 3}]`
+
+func run(t *testing.T) *metrics.Metrics {
+	provider, _ := jsonprov.New(strings.NewReader(input))
+	m := metrics.New("go")
+	proc := mocks.NewJobProcessor().
+		SetJobProvider(provider).
+		AddObserver(m)
+	m.SetStats(proc.Stats)
+	if err := proc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMetrics(t *testing.T) {
+
+	var buf bytes.Buffer
+	if _, err := run(t).WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		"# TYPE divider_jobs_read_total counter",
+		"divider_jobs_read_total 5",
+		`divider_jobs_rejected_total{reason="arg2_missing"} 1`,
+		`divider_jobs_rejected_total{reason="type_mismatch"} 1`,
+		`divider_jobs_divided_total{method="go"} 3`,
+		`divider_division_errors_total{method="go",reason="div_zero"} 1`,
+		"divider_results_reported_total 5",
+		"# TYPE divider_report_duration_seconds histogram",
+		`divider_report_duration_seconds_bucket{le="+Inf"} 5`,
+		"divider_report_duration_seconds_count 5",
+		"divider_pool_queued 0",
+		"divider_pool_job_duration_seconds_count 5",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("line %q missing in:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := run(t)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") ||
+		!strings.Contains(string(body), "divider_jobs_read_total 5\n") {
+		t.Fatalf("unexpected response: %s", body)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "divider.prom")
+	if err := run(t).WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(b), "divider_jobs_read_total 5\n") {
+		t.Fatalf("unexpected file (%v): %s", err, b)
+	}
+}
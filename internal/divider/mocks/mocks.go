@@ -3,7 +3,7 @@ package mocks
 import (
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"reflect"
 	"testing"
 
@@ -14,7 +14,7 @@ import (
 var (
 	// FakeLog is used with mocked JobProcessor.
 	// By default it uses writer that drops messages.
-	FakeLog = log.New(NewFakeWriter(), "", log.LstdFlags)
+	FakeLog = slog.New(slog.NewTextHandler(NewFakeWriter(), nil))
 
 	// FakeDivider is used with mocked JobProcessor.
 	// By default it is godiv.Divider.
@@ -44,13 +44,23 @@ func RunTestCases(t *testing.T, run func(*testing.T, TestCase)) {
 }
 
 // Validate is a helper for use inside the func provided to RunTestCases.
+// Results are compared by ID, Value and Valid members, as not every
+// reporter keeps reasons.
 func Validate(t *testing.T, test TestCase, actual []*divider.JobResult) {
-	if !reflect.DeepEqual(test.Results, actual) {
+	if !reflect.DeepEqual(withoutReasons(test.Results), withoutReasons(actual)) {
 		t.Fatalf("test case %s:\njobs: %s\nexpected: %s\ngot: %s",
 			test.Name, pretty(test.Jobs), pretty(test.Results), pretty(actual))
 	}
 }
 
+func withoutReasons(results []*divider.JobResult) []divider.JobResult {
+	stripped := make([]divider.JobResult, len(results))
+	for i, r := range results {
+		stripped[i] = divider.JobResult{ID: r.ID, Value: r.Value, Valid: r.Valid}
+	}
+	return stripped
+}
+
 func pretty(v interface{}) string {
 	s, _ := json.MarshalIndent(v, "", "\t")
 	return string(s)
@@ -29,3 +29,13 @@ func (f *FakeJobProvider) Next(job *divider.Job) error {
 	f.n++
 	return f.Fail()
 }
+
+// Offset returns the index of the next job.
+func (f *FakeJobProvider) Offset() int64 {
+	return int64(f.n)
+}
+
+// SetOffset makes the provider continue from the job with the index offset.
+func (f *FakeJobProvider) SetOffset(offset int64) {
+	f.n = int(offset)
+}
@@ -7,6 +7,7 @@ import "github.com/b-2019-apt-test/divider/internal/divider"
 type FakeResultReporter struct {
 	results []*divider.JobResult
 	*Failer
+	flushed, closed bool
 }
 
 // NewFakeResultReporter creates a new FakeResultReporter.
@@ -27,3 +28,30 @@ func (f *FakeResultReporter) Report(result *divider.JobResult) (err error) {
 func (f *FakeResultReporter) Results() []*divider.JobResult {
 	return f.results
 }
+
+// Size returns the number of saved results.
+func (f *FakeResultReporter) Size() int64 {
+	return int64(len(f.results))
+}
+
+// Flush marks the reporter as flushed.
+func (f *FakeResultReporter) Flush() error {
+	f.flushed = true
+	return nil
+}
+
+// Close marks the reporter as closed.
+func (f *FakeResultReporter) Close() error {
+	f.closed = true
+	return nil
+}
+
+// Flushed reports whether Flush was called.
+func (f *FakeResultReporter) Flushed() bool {
+	return f.flushed
+}
+
+// Closed reports whether Close was called.
+func (f *FakeResultReporter) Closed() bool {
+	return f.closed
+}
@@ -16,6 +16,10 @@ type Failer struct {
 // FailFn makes bad things.
 type FailFn func() error
 
+// PanicDivider panics on division by zero instead of returning error. It
+// imitates a failure inside of the division method, such as a bad native call.
+type PanicDivider struct{}
+
 // FakeWriter does not write anything.
 type FakeWriter struct{}
 
@@ -31,6 +35,9 @@ var (
 	// ErrPanicWriter is an error PanicWritter panics with.
 	ErrPanicWriter = errors.New("Panic should happen")
 
+	// ErrPanicDivider is an error PanicDivider panics with.
+	ErrPanicDivider = errors.New("Division panicked")
+
 	// PanicFailFn panics with ErrPanicWriter.
 	PanicFailFn = func() error { panic(ErrPanicWriter) }
 
@@ -80,6 +87,14 @@ func (f *Failer) Fail() error {
 	return nil
 }
 
+// Div divides a by b or panics with ErrPanicDivider if b is zero.
+func (d PanicDivider) Div(a, b int) (int, error) {
+	if b == 0 {
+		panic(ErrPanicDivider)
+	}
+	return a / b, nil
+}
+
 // NewFakeWriter creates a new FakeWriter.
 func NewFakeWriter() *FakeWriter {
 	return new(FakeWriter)
@@ -0,0 +1,199 @@
+// Package multiprov merges several job providers into one, so that a single
+// JobProcessor run processes several inputs.
+package multiprov
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+)
+
+// Input is a named job provider.
+type Input struct {
+	Name     string
+	Provider divider.JobProvider
+	// Index is the index of the first job of the provider in the input,
+	// e.g. if it continues an interrupted run.
+	Index uint64
+}
+
+// Provider implements divider.JobProvider over several inputs read one by
+// one. Every job gets Source and Index of its input. Terminal errors of
+// inputs are prefixed with the input name and terminate processing of all
+// the inputs.
+type Provider struct {
+	inputs []*Input
+	i      int
+}
+
+// New creates a new Provider which reads the inputs in order.
+func New(inputs ...Input) *Provider {
+	p := &Provider{}
+	for i := range inputs {
+		p.inputs = append(p.inputs, &inputs[i])
+	}
+	return p
+}
+
+// More reports whether there is another job in any of the inputs.
+func (p *Provider) More() bool {
+	for ; p.i < len(p.inputs); p.i++ {
+		if p.inputs[p.i].Provider.More() {
+			return true
+		}
+	}
+	return false
+}
+
+// Next reads the next job.
+func (p *Provider) Next(job *divider.Job) error {
+	if !p.More() {
+		return io.EOF
+	}
+	return next(p.inputs[p.i], job)
+}
+
+// Skip implements divider.Skipper. Inputs which are not Skippers are read.
+func (p *Provider) Skip() error {
+	if !p.More() {
+		return io.EOF
+	}
+	in := p.inputs[p.i]
+	s, ok := in.Provider.(divider.Skipper)
+	if !ok {
+		var job divider.Job
+		return next(in, &job)
+	}
+	in.Index++
+	if err := s.Skip(); err != nil && terminal(err) {
+		return fmt.Errorf("%s: %w", in.Name, err)
+	}
+	return nil
+}
+
+// Offset implements divider.Positioner: it returns the offset in the
+// current input, if it is a Positioner too. It only makes checkpoints
+// usable with a single input.
+func (p *Provider) Offset() int64 {
+	if p.i >= len(p.inputs) {
+		return 0
+	}
+	if pos, ok := p.inputs[p.i].Provider.(divider.Positioner); ok {
+		return pos.Offset()
+	}
+	return 0
+}
+
+// Close does nothing. It makes Provider interchangeable with Concurrent.
+func (p *Provider) Close() error {
+	return nil
+}
+
+// Concurrent is Provider which reads the inputs concurrently, so jobs of
+// different inputs are interleaved. Input offsets do not tell how far
+// processing got, so Concurrent is not a divider.Positioner.
+type Concurrent struct {
+	items chan item
+	next  *item
+	done  chan struct{}
+	once  sync.Once
+}
+
+type item struct {
+	job divider.Job
+	err error
+}
+
+// NewConcurrent creates a new Concurrent. Close must be called if
+// processing stops before the inputs are exhausted.
+func NewConcurrent(inputs ...Input) *Concurrent {
+	p := &Concurrent{
+		items: make(chan item, len(inputs)),
+		done:  make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(inputs))
+	for i := range inputs {
+		go func(in *Input) {
+			defer wg.Done()
+			p.read(in)
+		}(&inputs[i])
+	}
+	go func() {
+		wg.Wait()
+		close(p.items)
+	}()
+	return p
+}
+
+// read passes jobs of the input to Next until the input is exhausted, fails
+// or the provider is closed.
+func (p *Concurrent) read(in *Input) {
+	for in.Provider.More() {
+		var it item
+		it.err = next(in, &it.job)
+		select {
+		case p.items <- it:
+		case <-p.done:
+			return
+		}
+		if it.err != nil && terminal(it.err) {
+			return
+		}
+	}
+}
+
+// More reports whether there is another job in any of the inputs.
+func (p *Concurrent) More() bool {
+	if p.next == nil {
+		it, ok := <-p.items
+		if !ok {
+			return false
+		}
+		p.next = &it
+	}
+	return true
+}
+
+// Next reads the next job.
+func (p *Concurrent) Next(job *divider.Job) error {
+	if !p.More() {
+		return io.EOF
+	}
+	it := p.next
+	p.next = nil
+	*job = it.job
+	return it.err
+}
+
+// Skip implements divider.Skipper. The inputs are read anyway.
+func (p *Concurrent) Skip() error {
+	var job divider.Job
+	return p.Next(&job)
+}
+
+// Close stops reading of the inputs.
+func (p *Concurrent) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+func next(in *Input, job *divider.Job) error {
+	index := in.Index
+	in.Index++
+	err := in.Provider.Next(job)
+	job.Source, job.Index = in.Name, index
+	if err != nil && terminal(err) {
+		return fmt.Errorf("%s: %w", in.Name, err)
+	}
+	return err
+}
+
+func terminal(err error) bool {
+	var nterr *divider.NonTerminalError
+	return !errors.As(err, &nterr)
+}
@@ -0,0 +1,142 @@
+package multiprov_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
+	"github.com/b-2019-apt-test/divider/internal/divider/mocks"
+	"github.com/b-2019-apt-test/divider/internal/divider/multiprov"
+)
+
+func inputs(t *testing.T, srcs ...string) []multiprov.Input {
+	var in []multiprov.Input
+	for i, src := range srcs {
+		dec, err := jsonprov.New(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		in = append(in, multiprov.Input{Name: string(rune('a' + i)), Provider: dec})
+	}
+	return in
+}
+
+func run(t *testing.T, prov divider.JobProvider) ([]*divider.JobResult, divider.Summary, error) {
+	reporter := mocks.NewFakeResultReporter()
+	summary, err := mocks.NewJobProcessor().
+		SetJobProvider(prov).
+		SetResultReporter(reporter).
+		Run(context.Background())
+	return reporter.Results(), summary, err
+}
+
+func TestSequential(t *testing.T) {
+
+	prov := multiprov.New(inputs(t,
+		`[{"arg1": 4, "arg2": 2}, {"arg1": 1}]`,
+		`[]`,
+		`[{"arg1": 9, "arg2": 3}]`)...)
+	results, summary, err := run(t, prov)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []divider.JobResult{
+		{ID: 0, Value: 2, Valid: true, Source: "a", Index: 0},
+		{ID: 1, Source: "a", Index: 1},
+		{ID: 2, Value: 3, Valid: true, Source: "c", Index: 0},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, r := range results {
+		e := expected[i]
+		if r.ID != e.ID || r.Value != e.Value || r.Valid != e.Valid || r.Source != e.Source || r.Index != e.Index {
+			t.Fatalf("expected %+v, got %+v", e, *r)
+		}
+	}
+
+	sources := []divider.SourceSummary{
+		{Source: "a", Total: 2, Valid: 1, InvalidInput: 1},
+		{Source: "c", Total: 1, Valid: 1},
+	}
+	if len(summary.Sources) != len(sources) || summary.Sources[0] != sources[0] || summary.Sources[1] != sources[1] {
+		t.Fatalf("expected %+v, got %+v", sources, summary.Sources)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+
+	var srcs []string
+	for i := 0; i < 4; i++ {
+		srcs = append(srcs, `[{"arg1": 4, "arg2": 2}, {"arg1": 6, "arg2": 2}, {"arg1": 8, "arg2": 2}]`)
+	}
+	prov := multiprov.NewConcurrent(inputs(t, srcs...)...)
+	defer prov.Close()
+	results, summary, err := run(t, prov)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Valid != 12 || len(summary.Sources) != 4 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	// Jobs of every input keep their order.
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Source < results[j].Source })
+	for i, r := range results {
+		if r.Index != uint64(i%3) || r.Value != 2+i%3 {
+			t.Fatalf("unexpected result %+v", *r)
+		}
+	}
+}
+
+func TestTerminalError(t *testing.T) {
+
+	for _, concurrent := range []bool{false, true} {
+		in := inputs(t, `[{"arg1": 4, "arg2": 2}]`, `[{"arg1": 4, "arg2": 2}, {`)
+		var prov interface {
+			divider.JobProvider
+			Close() error
+		} = multiprov.New(in...)
+		if concurrent {
+			prov = multiprov.NewConcurrent(in...)
+		}
+		_, _, err := run(t, prov)
+		prov.Close()
+
+		var serr *jsonprov.SyntaxError
+		if !errors.As(err, &serr) || !strings.HasPrefix(err.Error(), "b: ") {
+			t.Fatalf("expected syntax error of input b, got: %v", err)
+		}
+	}
+}
+
+func TestConcurrentCheckpoint(t *testing.T) {
+
+	prov := multiprov.NewConcurrent(inputs(t, `[{"arg1": 4, "arg2": 2}, {"arg1": 6, "arg2": 2}]`)...)
+	defer prov.Close()
+	if _, ok := interface{}(prov).(divider.Positioner); ok {
+		t.Fatal("concurrent provider must not tell offsets")
+	}
+
+	// Offsets of a single input do not tell how far processing got either.
+	saved := 0
+	_, err := mocks.NewJobProcessor().
+		SetJobProvider(prov).
+		SetResultReporter(mocks.NewFakeResultReporter()).
+		SetCheckpoint(0, func(divider.Checkpoint) error {
+			saved++
+			return nil
+		}).
+		Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved != 0 {
+		t.Fatalf("expected no checkpoints, got %d", saved)
+	}
+}
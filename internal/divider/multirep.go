@@ -0,0 +1,134 @@
+package divider
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// ReporterPolicy tells MultiReporter what to do if one of its reporters
+// fails.
+type ReporterPolicy int
+
+const (
+	// FailRun makes the failure of the reporter fail the run.
+	FailRun ReporterPolicy = iota
+	// DisableOnError disables the failed reporter, the others go on.
+	DisableOnError
+)
+
+// MultiReporter is a ResultReporter which tees every result to several
+// reporters in order of registration. Flush and Close are forwarded to the
+// reporters implementing Flusher and io.Closer. If all the reporters are
+// disabled, the run fails.
+type MultiReporter struct {
+	outs []*teeOutput
+	log  *slog.Logger
+}
+
+type teeOutput struct {
+	name   string
+	rep    ResultReporter
+	policy ReporterPolicy
+	err    error
+}
+
+// NewMultiReporter creates a new MultiReporter without reporters.
+func NewMultiReporter() *MultiReporter {
+	return &MultiReporter{log: slog.New(slog.DiscardHandler)}
+}
+
+// Add registers the reporter named name with the error policy.
+func (m *MultiReporter) Add(name string, r ResultReporter, policy ReporterPolicy) *MultiReporter {
+	m.outs = append(m.outs, &teeOutput{name: name, rep: r, policy: policy})
+	return m
+}
+
+// SetLogger specifies the logger disabled reporters are reported to.
+func (m *MultiReporter) SetLogger(l *slog.Logger) *MultiReporter {
+	m.log = l
+	return m
+}
+
+// Report passes the result to every enabled reporter.
+func (m *MultiReporter) Report(result *JobResult) error {
+	return m.each(func(r ResultReporter) error {
+		return r.Report(result)
+	})
+}
+
+// Flush flushes every enabled reporter implementing Flusher.
+func (m *MultiReporter) Flush() error {
+	return m.each(func(r ResultReporter) error {
+		if f, ok := r.(Flusher); ok {
+			return f.Flush()
+		}
+		return nil
+	})
+}
+
+// Close closes every reporter implementing io.Closer, including disabled
+// ones. The first error is returned.
+func (m *MultiReporter) Close() (err error) {
+	for _, out := range m.outs {
+		if c, ok := out.rep.(io.Closer); ok {
+			if cerr := c.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	return
+}
+
+// Sizes returns sizes of outputs of the reporters implementing Sizer, in
+// order of registration. Size of other reporters is zero.
+func (m *MultiReporter) Sizes() []int64 {
+	sizes := make([]int64, len(m.outs))
+	for i, out := range m.outs {
+		if s, ok := out.rep.(Sizer); ok {
+			sizes[i] = s.Size()
+		}
+	}
+	return sizes
+}
+
+// Disabled returns names of the disabled reporters with their errors.
+func (m *MultiReporter) Disabled() map[string]error {
+	disabled := make(map[string]error)
+	for _, out := range m.outs {
+		if out.err != nil {
+			disabled[out.name] = out.err
+		}
+	}
+	return disabled
+}
+
+// each calls fn for every enabled reporter and applies the error policy.
+func (m *MultiReporter) each(fn func(ResultReporter) error) error {
+	var last error
+	enabled := 0
+	for _, out := range m.outs {
+		if out.err != nil {
+			continue
+		}
+		err := fn(out.rep)
+		if err == nil {
+			enabled++
+			continue
+		}
+		if out.policy == FailRun {
+			return err
+		}
+		out.err, last = err, err
+		m.log.Warn("reporter disabled", "reporter", out.name, "error", err)
+	}
+	if enabled == 0 {
+		if last == nil {
+			last = errNoReporters
+		}
+		return last
+	}
+	return nil
+}
+
+var errNoReporters = errors.New("no enabled reporters")
@@ -0,0 +1,141 @@
+package divider
+
+import "time"
+
+// Observer receives lifecycle events of JobProcessor. Methods are called
+// synchronously: RunStarted, RunEnded, JobRead and JobRejected from the
+// goroutine of Run, JobDivided from worker goroutines concurrently and
+// ResultReported from the result reporting goroutine. Observer must be safe
+// for concurrent use and return quickly, as it delays processing.
+type Observer interface {
+	RunStarted(RunEvent)
+	RunEnded(RunEvent)
+	JobRead(JobEvent)
+	JobRejected(JobEvent)
+	JobDivided(JobEvent)
+	ResultReported(JobEvent)
+}
+
+// RunEvent describes the start or the end of a run. Summary and Err are only
+// set at the end.
+type RunEvent struct {
+	Time    time.Time
+	Summary Summary
+	Err     error
+}
+
+// JobEvent describes a job at a stage of processing. Duration is the time
+// taken by the stage: reading the job, dividing or writing the result. Err
+// and Reason are the cause of the job failure, if any. Result is not set for
+// read and rejected jobs. The event must not be retained after the call.
+type JobEvent struct {
+	Job      *Job
+	Result   *JobResult
+	Duration time.Duration
+	Err      error
+	Reason   Reason
+}
+
+// ObserverFuncs implements Observer with optional callbacks. Nil callbacks
+// are skipped.
+type ObserverFuncs struct {
+	OnRunStart       func(RunEvent)
+	OnRunEnd         func(RunEvent)
+	OnJobRead        func(JobEvent)
+	OnJobRejected    func(JobEvent)
+	OnJobDivided     func(JobEvent)
+	OnResultReported func(JobEvent)
+}
+
+// RunStarted calls OnRunStart.
+func (o *ObserverFuncs) RunStarted(e RunEvent) {
+	if o.OnRunStart != nil {
+		o.OnRunStart(e)
+	}
+}
+
+// RunEnded calls OnRunEnd.
+func (o *ObserverFuncs) RunEnded(e RunEvent) {
+	if o.OnRunEnd != nil {
+		o.OnRunEnd(e)
+	}
+}
+
+// JobRead calls OnJobRead.
+func (o *ObserverFuncs) JobRead(e JobEvent) {
+	if o.OnJobRead != nil {
+		o.OnJobRead(e)
+	}
+}
+
+// JobRejected calls OnJobRejected.
+func (o *ObserverFuncs) JobRejected(e JobEvent) {
+	if o.OnJobRejected != nil {
+		o.OnJobRejected(e)
+	}
+}
+
+// JobDivided calls OnJobDivided.
+func (o *ObserverFuncs) JobDivided(e JobEvent) {
+	if o.OnJobDivided != nil {
+		o.OnJobDivided(e)
+	}
+}
+
+// ResultReported calls OnResultReported.
+func (o *ObserverFuncs) ResultReported(e JobEvent) {
+	if o.OnResultReported != nil {
+		o.OnResultReported(e)
+	}
+}
+
+// observers passes events to every observer in order.
+type observers []Observer
+
+func (os observers) RunStarted(e RunEvent) {
+	for _, o := range os {
+		o.RunStarted(e)
+	}
+}
+
+func (os observers) RunEnded(e RunEvent) {
+	for _, o := range os {
+		o.RunEnded(e)
+	}
+}
+
+func (os observers) JobRead(e JobEvent) {
+	for _, o := range os {
+		o.JobRead(e)
+	}
+}
+
+func (os observers) JobRejected(e JobEvent) {
+	for _, o := range os {
+		o.JobRejected(e)
+	}
+}
+
+func (os observers) JobDivided(e JobEvent) {
+	for _, o := range os {
+		o.JobDivided(e)
+	}
+}
+
+func (os observers) ResultReported(e JobEvent) {
+	for _, o := range os {
+		o.ResultReported(e)
+	}
+}
+
+// observer returns the observer of the run or nil if there are none, so
+// that the cost of events is a nil check.
+func (p *JobProcessor) observer() Observer {
+	switch len(p.observers) {
+	case 0:
+		return nil
+	case 1:
+		return p.observers[0]
+	}
+	return p.observers
+}
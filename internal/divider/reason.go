@@ -0,0 +1,91 @@
+package divider
+
+import (
+	"errors"
+
+	"github.com/b-2019-apt-test/divider/pkg/div"
+	"github.com/b-2019-apt-test/divider/pkg/pool"
+)
+
+// Reason is a code of the reason why a job result is invalid.
+type Reason string
+
+// Reasons of invalid job results.
+const (
+	ReasonNone         Reason = ""
+	ReasonArg1Missing  Reason = "arg1_missing"
+	ReasonArg2Missing  Reason = "arg2_missing"
+	ReasonTypeMismatch Reason = "type_mismatch"
+	ReasonInvalidInput Reason = "invalid_input"
+	ReasonRejected     Reason = "rejected"
+	ReasonFiltered     Reason = "filtered"
+	ReasonOutOfRange   Reason = "out_of_range"
+	ReasonDuplicate    Reason = "duplicate"
+	ReasonIDMissing    Reason = "id_missing"
+	ReasonDuplicateID  Reason = "duplicate_id"
+	ReasonDivZero      Reason = "div_zero"
+	ReasonDivFailed    Reason = "division_failed"
+	ReasonPanic        Reason = "panic"
+	ReasonTimeout      Reason = "timeout"
+)
+
+// ReasonError attaches the reason code to the error. JobProvider may return
+// it wrapped into NonTerminalError and Stage may return it to specify the
+// reason of job rejection.
+type ReasonError struct {
+	Code Reason
+	Err  error
+}
+
+func (e *ReasonError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ReasonError) Unwrap() error {
+	return e.Err
+}
+
+// InputReason returns the reason code of the job rejected with the error by
+// JobProvider or Stage.
+func InputReason(err error) Reason {
+	var rerr *ReasonError
+	var serr *StageError
+	switch {
+	case errors.Is(err, ErrArg1Missing):
+		return ReasonArg1Missing
+	case errors.Is(err, ErrArg2Missing):
+		return ReasonArg2Missing
+	case errors.As(err, &rerr):
+		return rerr.Code
+	case errors.As(err, &serr):
+		return ReasonRejected
+	}
+	return ReasonInvalidInput
+}
+
+// DivisionReason returns the reason code of the job failed on division with
+// the error.
+func DivisionReason(err error) Reason {
+	var rerr *ReasonError
+	var perr *pool.PanicError
+	switch {
+	case errors.As(err, &rerr):
+		return rerr.Code
+	case errors.As(err, &perr):
+		return ReasonPanic
+	case errors.Is(err, div.ErrDivZero):
+		return ReasonDivZero
+	case errors.Is(err, div.ErrTimeout):
+		return ReasonTimeout
+	}
+	return ReasonDivFailed
+}
+
+// reasonOf returns the reason code of the job failure.
+func reasonOf(task *workerTask) Reason {
+	if !task.job.Valid {
+		return InputReason(task.err)
+	}
+	return DivisionReason(task.err)
+}
@@ -0,0 +1,73 @@
+package divider
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Shard selects the part of jobs processed by JobProcessor, so that several
+// processes can divide the same input without splitting it. Jobs of other
+// shards are read, but neither divided nor reported. Results keep the
+// sequential IDs of the whole input.
+type Shard struct {
+	// Index is the number of the shard starting from 0, Count is the count
+	// of shards. Zero Count disables sharding.
+	Index, Count uint64
+	// ByInputID assigns jobs to shards by the hash of the input ID instead
+	// of the sequential ID. Jobs without input ID fall back to the
+	// sequential ID.
+	ByInputID bool
+}
+
+// ParseShard parses the shard given as "i/n".
+func ParseShard(s string) (Shard, error) {
+	var sh Shard
+	i, n, ok := strings.Cut(s, "/")
+	if !ok {
+		return sh, fmt.Errorf("invalid shard %q: %w", s, errShardFormat)
+	}
+	var err error
+	if sh.Index, err = strconv.ParseUint(i, 10, 64); err == nil {
+		sh.Count, err = strconv.ParseUint(n, 10, 64)
+	}
+	if err != nil {
+		return sh, fmt.Errorf("invalid shard %q: %v", s, err)
+	}
+	if sh.Count == 0 || sh.Index >= sh.Count {
+		return sh, fmt.Errorf("invalid shard %q: %w", s, errShardRange)
+	}
+	return sh, nil
+}
+
+var (
+	errShardFormat = errors.New("expected index/count")
+	errShardRange  = errors.New("index must be less than count")
+)
+
+func (s Shard) String() string {
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+// owns reports whether the job belongs to the shard.
+func (s Shard) owns(id uint64, inputID string) bool {
+	if s.Count == 0 {
+		return true
+	}
+	key := id
+	if s.ByInputID && len(inputID) != 0 {
+		h := fnv.New64a()
+		h.Write([]byte(inputID))
+		key = h.Sum64()
+	}
+	return key%s.Count == s.Index
+}
+
+// Skipper is implemented by job providers which can skip the next job
+// cheaper than reading it. JobProcessor skips jobs of other shards with it,
+// unless shards are assigned by the input ID, which must be read.
+type Skipper interface {
+	Skip() error
+}
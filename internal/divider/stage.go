@@ -0,0 +1,42 @@
+package divider
+
+// Stage pre-processes jobs between JobProvider and division. Apply may
+// modify the job or reject it by returning an error. Rejected jobs are
+// reported as invalid: the reason is taken from ReasonError, otherwise it is
+// ReasonRejected. Stages are only applied to valid jobs, in order of
+// registration and from a single goroutine, so they need no locking.
+type Stage interface {
+	Name() string
+	Apply(*Job) error
+}
+
+// StageError wraps the error of the stage which rejected the job. It is of
+// ErrInvalidInput category.
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return "stage " + e.Stage + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error of the stage.
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrInvalidInput.
+func (e *StageError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// applyStages runs the job through the stages until one rejects it.
+func (p *JobProcessor) applyStages(task *workerTask) error {
+	for _, s := range p.stages {
+		if err := s.Apply(task.job); err != nil {
+			return &StageError{Stage: s.Name(), Err: err}
+		}
+	}
+	return nil
+}
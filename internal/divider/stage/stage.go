@@ -0,0 +1,210 @@
+// Package stage provides built-in pre-processing stages of
+// divider.JobProcessor and parses them from specs given on the command line.
+package stage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+)
+
+type stage struct {
+	name  string
+	apply func(*divider.Job) error
+}
+
+func (s *stage) Name() string {
+	return s.name
+}
+
+func (s *stage) Apply(job *divider.Job) error {
+	return s.apply(job)
+}
+
+// New creates a stage named name from the function.
+func New(name string, apply func(*divider.Job) error) divider.Stage {
+	return &stage{name, apply}
+}
+
+func reject(code divider.Reason, format string, args ...interface{}) error {
+	return &divider.ReasonError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Filter rejects jobs matching the predicate with divider.ReasonFiltered.
+func Filter(name string, drop func(*divider.Job) bool) divider.Stage {
+	return New(name, func(job *divider.Job) error {
+		if drop(job) {
+			return reject(divider.ReasonFiltered, "job filtered out by %s", name)
+		}
+		return nil
+	})
+}
+
+// Range rejects jobs with operands out of [min, max] range with
+// divider.ReasonOutOfRange.
+func Range(min, max int) divider.Stage {
+	return New("range", func(job *divider.Job) error {
+		for _, arg := range []int{job.Arg1, job.Arg2} {
+			if arg < min || arg > max {
+				return reject(divider.ReasonOutOfRange,
+					"operand %d out of range [%d, %d]", arg, min, max)
+			}
+		}
+		return nil
+	})
+}
+
+// Clamp limits operands to [min, max] range.
+func Clamp(min, max int) divider.Stage {
+	clamp := func(v int) int {
+		if v < min {
+			return min
+		}
+		if v > max {
+			return max
+		}
+		return v
+	}
+	return New("clamp", func(job *divider.Job) error {
+		job.Arg1, job.Arg2 = clamp(job.Arg1), clamp(job.Arg2)
+		return nil
+	})
+}
+
+// Swap swaps the dividend and the divisor.
+func Swap() divider.Stage {
+	return New("swap", func(job *divider.Job) error {
+		job.Arg1, job.Arg2 = job.Arg2, job.Arg1
+		return nil
+	})
+}
+
+// Normalize makes the divisor non-negative by negating both operands, which
+// does not change the quotient.
+func Normalize() divider.Stage {
+	return New("normalize", func(job *divider.Job) error {
+		if job.Arg2 < 0 {
+			job.Arg1, job.Arg2 = -job.Arg1, -job.Arg2
+		}
+		return nil
+	})
+}
+
+// Dedup rejects jobs with the same operands as one of the previous jobs with
+// divider.ReasonDuplicate. It keeps every distinct pair of operands in
+// memory.
+func Dedup() divider.Stage {
+	seen := make(map[[2]int]bool)
+	return New("dedup", func(job *divider.Job) error {
+		key := [2]int{job.Arg1, job.Arg2}
+		if seen[key] {
+			return reject(divider.ReasonDuplicate, "duplicate of a previous job")
+		}
+		seen[key] = true
+		return nil
+	})
+}
+
+// ErrSpec is returned by Parse for invalid specs.
+var ErrSpec = errors.New("invalid stage spec")
+
+// Parse creates a stage from the spec:
+//
+//	drop:<arg><op><int>   Filter, e.g. drop:arg2=0; op is one of = != < <= > >=
+//	range:<min>:<max>     Range
+//	clamp:<min>:<max>     Clamp
+//	swap                  Swap
+//	normalize             Normalize
+//	dedup                 Dedup
+func Parse(spec string) (divider.Stage, error) {
+	name, args := spec, ""
+	if i := strings.IndexByte(spec, ':'); i >= 0 {
+		name, args = spec[:i], spec[i+1:]
+	}
+
+	switch name {
+	case "drop":
+		drop, err := parsePredicate(args)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrSpec, spec, err)
+		}
+		return Filter(spec, drop), nil
+	case "range", "clamp":
+		min, max, err := parseBounds(args)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrSpec, spec, err)
+		}
+		if name == "range" {
+			return Range(min, max), nil
+		}
+		return Clamp(min, max), nil
+	}
+
+	if len(args) != 0 {
+		return nil, fmt.Errorf("%w %q: unexpected arguments", ErrSpec, spec)
+	}
+	switch name {
+	case "swap":
+		return Swap(), nil
+	case "normalize":
+		return Normalize(), nil
+	case "dedup":
+		return Dedup(), nil
+	}
+	return nil, fmt.Errorf("%w %q: unknown stage", ErrSpec, spec)
+}
+
+func parseBounds(s string) (min, max int, err error) {
+	bounds := strings.Split(s, ":")
+	if len(bounds) != 2 {
+		return 0, 0, errors.New("expected <min>:<max>")
+	}
+	if min, err = strconv.Atoi(bounds[0]); err != nil {
+		return
+	}
+	if max, err = strconv.Atoi(bounds[1]); err != nil {
+		return
+	}
+	if min > max {
+		err = errors.New("min is greater than max")
+	}
+	return
+}
+
+var ops = []struct {
+	op   string
+	test func(a, b int) bool
+}{
+	// Two-character operators go first not to be taken for one-character.
+	{"!=", func(a, b int) bool { return a != b }},
+	{"<=", func(a, b int) bool { return a <= b }},
+	{">=", func(a, b int) bool { return a >= b }},
+	{"=", func(a, b int) bool { return a == b }},
+	{"<", func(a, b int) bool { return a < b }},
+	{">", func(a, b int) bool { return a > b }},
+}
+
+func parsePredicate(s string) (func(*divider.Job) bool, error) {
+	for _, op := range ops {
+		i := strings.Index(s, op.op)
+		if i < 0 {
+			continue
+		}
+		v, err := strconv.Atoi(s[i+len(op.op):])
+		if err != nil {
+			return nil, err
+		}
+		test := op.test
+		switch s[:i] {
+		case "arg1":
+			return func(job *divider.Job) bool { return test(job.Arg1, v) }, nil
+		case "arg2":
+			return func(job *divider.Job) bool { return test(job.Arg2, v) }, nil
+		}
+		return nil, fmt.Errorf("unknown field %q", s[:i])
+	}
+	return nil, errors.New("expected <arg><op><int>")
+}
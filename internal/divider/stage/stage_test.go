@@ -0,0 +1,95 @@
+package stage_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/mocks"
+	"github.com/b-2019-apt-test/divider/internal/divider/stage"
+)
+
+func TestParse(t *testing.T) {
+
+	tests := []struct {
+		spec   string
+		job    divider.Job
+		result divider.Job
+		reason divider.Reason
+	}{
+		{"drop:arg2=0", divider.Job{Arg1: 1, Arg2: 0}, divider.Job{}, divider.ReasonFiltered},
+		{"drop:arg2=0", divider.Job{Arg1: 1, Arg2: 2}, divider.Job{Arg1: 1, Arg2: 2}, ""},
+		{"drop:arg1<=-1", divider.Job{Arg1: -1, Arg2: 2}, divider.Job{}, divider.ReasonFiltered},
+		{"drop:arg1!=5", divider.Job{Arg1: 5, Arg2: 2}, divider.Job{Arg1: 5, Arg2: 2}, ""},
+		{"range:-10:10", divider.Job{Arg1: 11, Arg2: 2}, divider.Job{}, divider.ReasonOutOfRange},
+		{"range:-10:10", divider.Job{Arg1: -10, Arg2: 10}, divider.Job{Arg1: -10, Arg2: 10}, ""},
+		{"clamp:-10:10", divider.Job{Arg1: 11, Arg2: -20}, divider.Job{Arg1: 10, Arg2: -10}, ""},
+		{"swap", divider.Job{Arg1: 1, Arg2: 2}, divider.Job{Arg1: 2, Arg2: 1}, ""},
+		{"normalize", divider.Job{Arg1: 4, Arg2: -2}, divider.Job{Arg1: -4, Arg2: 2}, ""},
+	}
+
+	for _, test := range tests {
+		s, err := stage.Parse(test.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", test.spec, err)
+		}
+		job := test.job
+		err = s.Apply(&job)
+
+		var rerr *divider.ReasonError
+		if test.reason != "" {
+			if !errors.As(err, &rerr) || rerr.Code != test.reason {
+				t.Fatalf("%s %+v: expected %s, got: %v", test.spec, test.job, test.reason, err)
+			}
+			continue
+		}
+		if err != nil || job.Arg1 != test.result.Arg1 || job.Arg2 != test.result.Arg2 {
+			t.Fatalf("%s %+v: expected %+v, got %+v (%v)", test.spec, test.job, test.result, job, err)
+		}
+	}
+
+	for _, spec := range []string{"", "drop", "drop:arg3=1", "drop:arg1~1", "range:1", "clamp:2:1", "swap:1", "foo"} {
+		if _, err := stage.Parse(spec); !errors.Is(err, stage.ErrSpec) {
+			t.Fatalf("%q: expected ErrSpec, got: %v", spec, err)
+		}
+	}
+}
+
+func TestStages(t *testing.T) {
+
+	jobs := []*divider.Job{
+		{Arg1: 4, Arg2: 2, Valid: true},
+		{Arg1: 1, Arg2: 0, Valid: true},
+		{Arg1: 6, Arg2: -3, Valid: true},
+		{Arg1: 4, Arg2: 2, Valid: true},
+		{Arg1: 100, Arg2: 1, Valid: true},
+	}
+
+	reporter := mocks.NewFakeResultReporter()
+	err := mocks.NewJobProcessor().
+		SetJobProvider(mocks.NewFakeJobProvider(jobs)).
+		SetResultReporter(reporter).
+		AddStage(stage.Filter("zero divisor", func(job *divider.Job) bool { return job.Arg2 == 0 })).
+		AddStage(stage.Normalize()).
+		AddStage(stage.Dedup()).
+		AddStage(stage.Clamp(-10, 10)).
+		Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*divider.JobResult{
+		{ID: 0, Value: 2, Valid: true},
+		{ID: 1, Reason: divider.ReasonFiltered},
+		{ID: 2, Value: -2, Valid: true},
+		{ID: 3, Reason: divider.ReasonDuplicate},
+		{ID: 4, Value: 10, Valid: true},
+	}
+	results := reporter.Results()
+	for i, r := range expected {
+		a := results[i]
+		if a.ID != r.ID || a.Value != r.Value || a.Valid != r.Valid || a.Reason != r.Reason {
+			t.Fatalf("expected %+v, got %+v", r, a)
+		}
+	}
+}
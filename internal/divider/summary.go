@@ -0,0 +1,119 @@
+package divider
+
+import "time"
+
+// DefaultErrorSamples is the default count of error samples kept in Summary.
+const DefaultErrorSamples = 10
+
+// Kinds of ErrorSample.
+const (
+	KindInvalidInput  = "invalid_input"
+	KindDivisionError = "division_error"
+)
+
+// Summary describes the outcome of a JobProcessor run.
+type Summary struct {
+	// Total is the count of reported results.
+	Total uint64 `json:"total"`
+	// Valid is the count of successfully processed jobs.
+	Valid uint64 `json:"valid"`
+	// InvalidInput is the count of jobs rejected by JobProvider.
+	InvalidInput uint64 `json:"invalid_input"`
+	// DivErrors is the count of jobs failed on division.
+	DivErrors uint64 `json:"division_errors"`
+	// Duration is the time taken by the run.
+	Duration time.Duration `json:"duration_ns"`
+	// NextID is the ID of the first job which is not completed.
+	NextID uint64 `json:"next_id"`
+	// Throughput is the average count of jobs processed per second.
+	Throughput float64 `json:"throughput"`
+	// Errors are samples of the first errors occurred during the run.
+	Errors []ErrorSample `json:"errors,omitempty"`
+	// Threshold is the threshold which stopped the run, if any.
+	Threshold *ThresholdError `json:"threshold,omitempty"`
+	// Sources break the counts down by the input, if the provider tells
+	// the source of jobs. They are in order of the first reported result.
+	Sources []SourceSummary `json:"sources,omitempty"`
+}
+
+// SourceSummary holds the counts of Summary for a single input.
+type SourceSummary struct {
+	Source       string `json:"source"`
+	Total        uint64 `json:"total"`
+	Valid        uint64 `json:"valid"`
+	InvalidInput uint64 `json:"invalid_input"`
+	DivErrors    uint64 `json:"division_errors"`
+}
+
+// ErrorSample describes an error of a particular job.
+type ErrorSample struct {
+	ID    uint64 `json:"id"`
+	Kind  string `json:"kind"`
+	Error string `json:"error"`
+}
+
+// tally accumulates Summary. It is only accessed by the result reporting
+// goroutine until the run is over.
+type tally struct {
+	Summary
+	samples int
+	sources map[string]int
+}
+
+func (t *tally) add(task *workerTask) {
+	src := t.source(task.result.Source)
+	t.Total++
+	src.Total++
+	switch {
+	case !task.job.Valid:
+		t.InvalidInput++
+		src.InvalidInput++
+		t.sample(task, KindInvalidInput)
+	case !task.result.Valid:
+		t.DivErrors++
+		src.DivErrors++
+		t.sample(task, KindDivisionError)
+	default:
+		t.Valid++
+		src.Valid++
+	}
+}
+
+// source returns the summary of the source. Jobs without the source are
+// counted to a throwaway one.
+func (t *tally) source(name string) *SourceSummary {
+	if len(name) == 0 {
+		return &SourceSummary{}
+	}
+	i, ok := t.sources[name]
+	if !ok {
+		if t.sources == nil {
+			t.sources = make(map[string]int)
+		}
+		i = len(t.Sources)
+		t.sources[name] = i
+		t.Sources = append(t.Sources, SourceSummary{Source: name})
+	}
+	return &t.Sources[i]
+}
+
+func (t *tally) sample(task *workerTask, kind string) {
+	if len(t.Errors) >= t.samples {
+		return
+	}
+	s := ErrorSample{ID: task.result.ID, Kind: kind}
+	if task.err != nil {
+		s.Error = task.err.Error()
+	}
+	t.Errors = append(t.Errors, s)
+}
+
+func (t *tally) summary(d time.Duration) Summary {
+	s := t.Summary
+	s.Sources = append([]SourceSummary(nil), t.Sources...)
+	s.Duration = d
+	if d > 0 {
+		s.Throughput = float64(s.Total) / d.Seconds()
+	}
+	return s
+}
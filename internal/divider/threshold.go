@@ -0,0 +1,125 @@
+package divider
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Triggers of ThresholdError.
+const (
+	TriggerMaxInvalid            = "max_invalid"
+	TriggerMaxInvalidRatio       = "max_invalid_ratio"
+	TriggerMaxConsecutiveDivErrs = "max_consecutive_division_errors"
+)
+
+// Thresholds stop processing of input which is mostly garbage. Zero values
+// disable the corresponding thresholds.
+type Thresholds struct {
+	// MaxInvalid is the max count of invalid results: rejected jobs and
+	// division errors.
+	MaxInvalid uint64
+	// MaxInvalidRatio is the max ratio of invalid results among the last
+	// Window results. It is checked once Window results are reported.
+	MaxInvalidRatio float64
+	Window          uint
+	// MaxConsecutiveDivErrors is the max count of division errors in a row.
+	MaxConsecutiveDivErrors uint64
+}
+
+// ErrThresholdExceeded is the category of ThresholdError.
+var ErrThresholdExceeded = errors.New("error threshold exceeded")
+
+// ThresholdError is returned by JobProcessor if one of Thresholds is
+// exceeded. It terminates processing: no more jobs are read, but the jobs
+// already read are reported. Value is the value which exceeded Limit at the
+// result of job JobID.
+type ThresholdError struct {
+	Trigger string  `json:"trigger"`
+	Limit   float64 `json:"limit"`
+	Value   float64 `json:"value"`
+	JobID   uint64  `json:"job_id"`
+}
+
+func (e *ThresholdError) Error() string {
+	return fmt.Sprintf("%s threshold exceeded at job %d: %s > %s", e.Trigger, e.JobID,
+		strconv.FormatFloat(e.Value, 'g', -1, 64), strconv.FormatFloat(e.Limit, 'g', -1, 64))
+}
+
+// Is reports whether target is ErrThresholdExceeded.
+func (e *ThresholdError) Is(target error) bool {
+	return target == ErrThresholdExceeded
+}
+
+// thresholdCheck tracks results against Thresholds. It is only accessed by
+// the result reporting goroutine.
+type thresholdCheck struct {
+	Thresholds
+
+	invalid     uint64
+	consecutive uint64
+
+	// window is a ring of the last results, true for invalid ones.
+	window        []bool
+	next          int
+	full          bool
+	windowInvalid uint64
+}
+
+// newThresholdCheck returns nil if all the thresholds are disabled.
+func newThresholdCheck(t Thresholds) *thresholdCheck {
+	if t.MaxInvalid == 0 && t.MaxConsecutiveDivErrors == 0 &&
+		(t.MaxInvalidRatio <= 0 || t.Window == 0) {
+		return nil
+	}
+	c := &thresholdCheck{Thresholds: t}
+	if t.MaxInvalidRatio > 0 && t.Window > 0 {
+		c.window = make([]bool, t.Window)
+	}
+	return c
+}
+
+// add accounts the reported result and returns ThresholdError if a
+// threshold is exceeded.
+func (c *thresholdCheck) add(task *workerTask) *ThresholdError {
+
+	invalid := !task.result.Valid
+	divErr := invalid && task.job.Valid
+
+	if invalid {
+		c.invalid++
+	}
+	if divErr {
+		c.consecutive++
+	} else {
+		c.consecutive = 0
+	}
+
+	if c.window != nil {
+		if c.window[c.next] {
+			c.windowInvalid--
+		}
+		c.window[c.next] = invalid
+		if invalid {
+			c.windowInvalid++
+		}
+		if c.next++; c.next == len(c.window) {
+			c.next, c.full = 0, true
+		}
+	}
+
+	id := task.result.ID
+	switch {
+	case c.MaxInvalid > 0 && c.invalid > c.MaxInvalid:
+		return &ThresholdError{TriggerMaxInvalid, float64(c.MaxInvalid), float64(c.invalid), id}
+	case c.MaxConsecutiveDivErrors > 0 && c.consecutive > c.MaxConsecutiveDivErrors:
+		return &ThresholdError{TriggerMaxConsecutiveDivErrs,
+			float64(c.MaxConsecutiveDivErrors), float64(c.consecutive), id}
+	case c.full:
+		ratio := float64(c.windowInvalid) / float64(len(c.window))
+		if ratio > c.MaxInvalidRatio {
+			return &ThresholdError{TriggerMaxInvalidRatio, c.MaxInvalidRatio, ratio, id}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Package validate checks jobs of a JobProvider without dividing them.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
+)
+
+// DefaultSamples is the default count of samples kept for every problem.
+const DefaultSamples = 5
+
+// Report is the outcome of validation.
+type Report struct {
+	// Jobs is the count of jobs read.
+	Jobs uint64 `json:"jobs"`
+	// Valid is the count of jobs without problems.
+	Valid uint64 `json:"valid"`
+	// Problems are the counts of invalid jobs by reason.
+	Problems map[divider.Reason]uint64 `json:"problems,omitempty"`
+	// Samples are the first invalid jobs of every reason.
+	Samples []Sample `json:"samples,omitempty"`
+	// Fatal is the error which stopped reading of the input, if any.
+	Fatal *Fatal `json:"fatal,omitempty"`
+}
+
+// Sample describes a problem of a particular job. Number is the position of
+// the job in the input starting from zero.
+type Sample struct {
+	Number uint64         `json:"number"`
+	Source string         `json:"source,omitempty"`
+	Index  uint64         `json:"index,omitempty"`
+	Reason divider.Reason `json:"reason"`
+	Error  string         `json:"error"`
+}
+
+// Fatal describes the error which stopped reading of the input. Line and
+// Column are set for JSON syntax errors of seekable inputs.
+type Fatal struct {
+	Error  string `json:"error"`
+	Offset int64  `json:"offset,omitempty"`
+	Line   int64  `json:"line,omitempty"`
+	Column int64  `json:"column,omitempty"`
+}
+
+// Validator reads all the jobs of the provider and checks them the same
+// way JobProcessor does, but instead of division it checks that operands are
+// in range and the divisor is not zero.
+type Validator struct {
+	min, max int
+	samples  int
+}
+
+// New creates a new Validator. Operands must be in int32 range, which is
+// what the division library takes.
+func New() *Validator {
+	return &Validator{min: math.MinInt32, max: math.MaxInt32, samples: DefaultSamples}
+}
+
+// SetRange sets the range of valid operands.
+func (v *Validator) SetRange(min, max int) *Validator {
+	v.min, v.max = min, max
+	return v
+}
+
+// SetSamples sets the count of samples kept for every problem.
+func (v *Validator) SetSamples(n int) *Validator {
+	v.samples = n
+	return v
+}
+
+// Run validates the jobs of the provider. Terminal errors of the provider
+// are recorded in Report.Fatal and returned.
+func (v *Validator) Run(prov divider.JobProvider) (Report, error) {
+	r := Report{Problems: make(map[divider.Reason]uint64)}
+	sampled := make(map[divider.Reason]int)
+
+	for prov.More() {
+		var job divider.Job
+		err := prov.Next(&job)
+		if err != nil && !errors.Is(err, divider.ErrInvalidInput) {
+			r.Fatal = fatal(err)
+			return r, err
+		}
+		n := r.Jobs
+		r.Jobs++
+
+		var reason divider.Reason
+		switch {
+		case err != nil:
+			reason = divider.InputReason(err)
+		case job.Arg2 == 0:
+			reason, err = divider.ReasonDivZero, errDivZero
+		case job.Arg1 < v.min || job.Arg1 > v.max || job.Arg2 < v.min || job.Arg2 > v.max:
+			reason = divider.ReasonOutOfRange
+			err = fmt.Errorf("operand out of range [%d, %d]", v.min, v.max)
+		default:
+			r.Valid++
+			continue
+		}
+
+		r.Problems[reason]++
+		if sampled[reason] < v.samples {
+			sampled[reason]++
+			r.Samples = append(r.Samples, Sample{Number: n, Source: job.Source,
+				Index: job.Index, Reason: reason, Error: err.Error()})
+		}
+	}
+	return r, nil
+}
+
+var errDivZero = errors.New("divisor is zero")
+
+func fatal(err error) *Fatal {
+	f := &Fatal{Error: err.Error()}
+	var serr *jsonprov.SyntaxError
+	if errors.As(err, &serr) {
+		f.Offset, f.Line, f.Column = serr.Offset, serr.Line, serr.Column
+	}
+	return f
+}
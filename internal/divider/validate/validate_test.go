@@ -0,0 +1,64 @@
+package validate_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/b-2019-apt-test/divider/internal/divider"
+	"github.com/b-2019-apt-test/divider/internal/divider/jsonprov"
+	"github.com/b-2019-apt-test/divider/internal/divider/validate"
+)
+
+func TestRun(t *testing.T) {
+
+	input := `[{"arg1": 4, "arg2": 2}, {"arg1": 1}, {"arg1": 1, "arg2": 0},
+		{"arg1": "x", "arg2": 1}, {"arg1": 1000, "arg2": 1}, {"arg2": 5}, {"arg1": 3, "arg2": 0}]`
+	prov, _ := jsonprov.New(strings.NewReader(input))
+
+	r, err := validate.New().SetRange(-100, 100).SetSamples(1).Run(prov)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems := map[divider.Reason]uint64{
+		divider.ReasonArg2Missing:  1,
+		divider.ReasonArg1Missing:  1,
+		divider.ReasonTypeMismatch: 1,
+		divider.ReasonDivZero:      2,
+		divider.ReasonOutOfRange:   1,
+	}
+	if r.Jobs != 7 || r.Valid != 1 || len(r.Problems) != len(problems) {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	for reason, n := range problems {
+		if r.Problems[reason] != n {
+			t.Fatalf("expected %d %s, got %d", n, reason, r.Problems[reason])
+		}
+	}
+	if len(r.Samples) != len(problems) || r.Samples[1].Number != 2 || r.Samples[1].Reason != divider.ReasonDivZero {
+		t.Fatalf("unexpected samples: %+v", r.Samples)
+	}
+}
+
+func TestFatal(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	os.WriteFile(path, []byte("[{\"arg1\": 1, \"arg2\": 1},\n{\"arg1\": }]"), 0644)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	prov, _ := jsonprov.New(f)
+
+	r, err := validate.New().Run(prov)
+	if !errors.Is(err, divider.ErrMalformedInput) {
+		t.Fatalf("expected malformed input, got: %v", err)
+	}
+	if r.Jobs != 1 || r.Fatal == nil || r.Fatal.Line != 2 || r.Fatal.Column != 10 {
+		t.Fatalf("unexpected report: %+v %+v", r, r.Fatal)
+	}
+}
@@ -0,0 +1,46 @@
+package atomicfile_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/b-2019-apt-test/divider/pkg/atomicfile"
+)
+
+func TestWrite(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	write := func(s string) func(io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := io.WriteString(w, s)
+			return err
+		}
+	}
+
+	if err := atomicfile.Write(path, write("first")); err != nil {
+		t.Fatal(err)
+	}
+	errWrite := errors.New("write failed")
+	err := atomicfile.Write(path, func(w io.Writer) error {
+		write("second")(w)
+		return errWrite
+	})
+	if err != errWrite {
+		t.Fatalf("expected write error, got: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil || string(b) != "first" {
+		t.Fatalf("expected previous content, got %q, %v", b, err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0644 {
+		t.Fatalf("expected 0644 mode, got %v, %v", fi.Mode(), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("temporary file left: %v", entries)
+	}
+}
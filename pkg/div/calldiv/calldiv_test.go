@@ -5,6 +5,7 @@ import (
 
 	"github.com/b-2019-apt-test/divider/pkg/div/calldiv"
 	"github.com/b-2019-apt-test/divider/pkg/div/divtest"
+	"github.com/b-2019-apt-test/divider/pkg/pool"
 )
 
 func TestCasesCallDiv(t *testing.T) {
@@ -18,3 +19,11 @@ func BenchmarkCallDiv(b *testing.B) {
 func BenchmarkCallDivParallel(b *testing.B) {
 	divtest.BenchmarkParallel(b, calldiv.Divider)
 }
+
+func BenchmarkCallDivPool(b *testing.B) {
+	divtest.BenchmarkPool(b, calldiv.Divider)
+}
+
+func BenchmarkCallDivPoolChunked(b *testing.B) {
+	divtest.BenchmarkPool(b, calldiv.Divider, pool.ChunkSize(64))
+}
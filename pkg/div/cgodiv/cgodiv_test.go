@@ -5,6 +5,7 @@ import (
 
 	"github.com/b-2019-apt-test/divider/pkg/div/cgodiv"
 	"github.com/b-2019-apt-test/divider/pkg/div/divtest"
+	"github.com/b-2019-apt-test/divider/pkg/pool"
 )
 
 func TestCasesCGODiv(t *testing.T) {
@@ -18,3 +19,11 @@ func BenchmarkCGODiv(b *testing.B) {
 func BenchmarkCGODivParallel(b *testing.B) {
 	divtest.BenchmarkParallel(b, cgodiv.Divider)
 }
+
+func BenchmarkCGODivPool(b *testing.B) {
+	divtest.BenchmarkPool(b, cgodiv.Divider)
+}
+
+func BenchmarkCGODivPoolChunked(b *testing.B) {
+	divtest.BenchmarkPool(b, cgodiv.Divider, pool.ChunkSize(64))
+}
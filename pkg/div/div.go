@@ -9,5 +9,10 @@ type Divider interface {
 	Div(int, int) (int, error)
 }
 
-// ErrDivZero specifies that divisor equals zero.
-var ErrDivZero = errors.New("Division by zero")
+var (
+	// ErrDivZero specifies that divisor equals zero.
+	ErrDivZero = errors.New("Division by zero")
+
+	// ErrTimeout specifies that division took too long.
+	ErrTimeout = errors.New("Division timed out")
+)
@@ -1,9 +1,11 @@
 package divtest
 
 import (
+	"runtime"
 	"testing"
 
 	"github.com/b-2019-apt-test/divider/pkg/div"
+	"github.com/b-2019-apt-test/divider/pkg/pool"
 )
 
 type testCase struct {
@@ -50,7 +52,7 @@ func Benchmark(b *testing.B, d div.Divider) {
 	})
 }
 
-// BenchmarkParallel
+// BenchmarkParallel performs parallel benchmark of the divider.
 func BenchmarkParallel(b *testing.B, d div.Divider) {
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
@@ -58,3 +60,40 @@ func BenchmarkParallel(b *testing.B, d div.Divider) {
 		}
 	})
 }
+
+// BenchmarkPool runs divider in the worker pool with the default count of
+// workers of the divider CLI (1024 per CPU), with both channel-based and
+// work-stealing schedulers.
+func BenchmarkPool(b *testing.B, d div.Divider, opts ...pool.Option) {
+	wc := uint(runtime.NumCPU() * 1024)
+	b.Run("Channel", func(b *testing.B) {
+		benchmarkPool(b, pool.New(divWorker{d}, wc, opts...))
+	})
+	stealing := append(opts[:len(opts):len(opts)], pool.WorkStealing())
+	b.Run("WorkStealing", func(b *testing.B) {
+		benchmarkPool(b, pool.New(divWorker{d}, wc, stealing...))
+	})
+}
+
+// benchmarkPool measures processing of b.N jobs by the pool. Starting of
+// the workers is not measured.
+func benchmarkPool(b *testing.B, p *pool.Pool) {
+	b.ResetTimer()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			p.Put(i + 1)
+		}
+		p.Close()
+	}()
+	for range p.Consume() {
+	}
+}
+
+type divWorker struct {
+	d div.Divider
+}
+
+func (w divWorker) Process(x interface{}) interface{} {
+	r, _ := w.d.Div(1e9, x.(int))
+	return r
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/b-2019-apt-test/divider/pkg/div/divtest"
 	"github.com/b-2019-apt-test/divider/pkg/div/godiv"
+	"github.com/b-2019-apt-test/divider/pkg/pool"
 )
 
 func TestCasesGoDiv(t *testing.T) {
@@ -18,3 +19,11 @@ func BenchmarkGoDiv(b *testing.B) {
 func BenchmarkGoDivParallel(b *testing.B) {
 	divtest.BenchmarkParallel(b, godiv.Divider)
 }
+
+func BenchmarkGoDivPool(b *testing.B) {
+	divtest.BenchmarkPool(b, godiv.Divider)
+}
+
+func BenchmarkGoDivPoolChunked(b *testing.B) {
+	divtest.BenchmarkPool(b, godiv.Divider, pool.ChunkSize(64))
+}
@@ -0,0 +1,28 @@
+package limitdiv
+
+import (
+	"github.com/b-2019-apt-test/divider/pkg/div"
+	"github.com/b-2019-apt-test/divider/pkg/ratelimit"
+)
+
+// Divider limits the rate of calls to the underlying divider.
+type Divider struct {
+	d div.Divider
+	l *ratelimit.Limiter
+}
+
+// New wraps divider d with limiter l.
+func New(d div.Divider, l *ratelimit.Limiter) *Divider {
+	return &Divider{d: d, l: l}
+}
+
+// Div waits for the limiter and divides a by b with the underlying divider.
+// Division by zero is rejected without waiting as it never reaches the
+// underlying divider.
+func (d *Divider) Div(a, b int) (int, error) {
+	if b == 0 {
+		return 0, div.ErrDivZero
+	}
+	d.l.Wait()
+	return d.d.Div(a, b)
+}
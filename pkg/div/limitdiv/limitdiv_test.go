@@ -0,0 +1,18 @@
+package limitdiv_test
+
+import (
+	"testing"
+
+	"github.com/b-2019-apt-test/divider/pkg/div/divtest"
+	"github.com/b-2019-apt-test/divider/pkg/div/godiv"
+	"github.com/b-2019-apt-test/divider/pkg/div/limitdiv"
+	"github.com/b-2019-apt-test/divider/pkg/ratelimit"
+)
+
+func TestCasesLimitDiv(t *testing.T) {
+	divtest.Cases(t, limitdiv.New(godiv.Divider, ratelimit.New(1e6, 1)))
+}
+
+func BenchmarkLimitDivParallel(b *testing.B) {
+	divtest.BenchmarkParallel(b, limitdiv.New(godiv.Divider, ratelimit.New(1e9, 1000)))
+}
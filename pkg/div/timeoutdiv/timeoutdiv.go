@@ -0,0 +1,85 @@
+package timeoutdiv
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/b-2019-apt-test/divider/pkg/div"
+)
+
+// DefaultMaxHung is the default max count of timed out calls still running.
+const DefaultMaxHung = 1024
+
+// Divider limits the time of calls to the underlying divider.
+type Divider struct {
+	d       div.Divider
+	timeout time.Duration
+	maxHung int64
+	hung    atomic.Int64
+}
+
+// New wraps divider d with timeout. Zero timeout does not limit calls.
+func New(d div.Divider, timeout time.Duration) *Divider {
+	return &Divider{d: d, timeout: timeout, maxHung: DefaultMaxHung}
+}
+
+// SetMaxHung sets the max count of timed out calls still running. Once it
+// is reached, Div fails with div.ErrTimeout without calling the underlying
+// divider until some of them return.
+func (d *Divider) SetMaxHung(n int) *Divider {
+	d.maxHung = int64(n)
+	return d
+}
+
+type result struct {
+	v   int
+	err error
+}
+
+// Call states.
+const (
+	running int32 = iota
+	returned
+	abandoned
+)
+
+// Div divides a by b with the underlying divider. If it takes longer than
+// the timeout, Div returns div.ErrTimeout. The call can not be interrupted,
+// so it goes on in background and its result is dropped.
+func (d *Divider) Div(a, b int) (int, error) {
+	if d.timeout <= 0 {
+		return d.d.Div(a, b)
+	}
+	if b == 0 {
+		return 0, div.ErrDivZero
+	}
+	if d.hung.Load() >= d.maxHung {
+		return 0, div.ErrTimeout
+	}
+
+	var state atomic.Int32
+	c := make(chan result, 1)
+	go func() {
+		v, err := d.d.Div(a, b)
+		c <- result{v, err}
+		if !state.CompareAndSwap(running, returned) {
+			d.hung.Add(-1)
+		}
+	}()
+
+	t := time.NewTimer(d.timeout)
+	defer t.Stop()
+	select {
+	case r := <-c:
+		return r.v, r.err
+	case <-t.C:
+		d.hung.Add(1)
+		if !state.CompareAndSwap(running, abandoned) {
+			// The call returned just in time.
+			d.hung.Add(-1)
+			r := <-c
+			return r.v, r.err
+		}
+		return 0, div.ErrTimeout
+	}
+}
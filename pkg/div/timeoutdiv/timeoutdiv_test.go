@@ -0,0 +1,81 @@
+package timeoutdiv_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/b-2019-apt-test/divider/pkg/div"
+	"github.com/b-2019-apt-test/divider/pkg/div/divtest"
+	"github.com/b-2019-apt-test/divider/pkg/div/godiv"
+	"github.com/b-2019-apt-test/divider/pkg/div/timeoutdiv"
+)
+
+func TestCasesTimeoutDiv(t *testing.T) {
+	divtest.Cases(t, timeoutdiv.New(godiv.Divider, time.Second))
+}
+
+type slowDivider time.Duration
+
+func (d slowDivider) Div(a, b int) (int, error) {
+	time.Sleep(time.Duration(d))
+	return a / b, nil
+}
+
+func TestTimeout(t *testing.T) {
+	d := timeoutdiv.New(slowDivider(time.Second), 10*time.Millisecond)
+	if _, err := d.Div(4, 2); err != div.ErrTimeout {
+		t.Fatalf("expected ErrTimeout, got: %v", err)
+	}
+}
+
+// hungDivider blocks until release is closed.
+type hungDivider struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (d *hungDivider) Div(a, b int) (int, error) {
+	d.calls.Add(1)
+	<-d.release
+	return a / b, nil
+}
+
+func TestMaxHung(t *testing.T) {
+	hung := &hungDivider{release: make(chan struct{})}
+	d := timeoutdiv.New(hung, 10*time.Millisecond).SetMaxHung(3)
+
+	for i := 0; i < 5; i++ {
+		if _, err := d.Div(4, 2); err != div.ErrTimeout {
+			t.Fatalf("call %d: expected ErrTimeout, got: %v", i, err)
+		}
+	}
+	if n := hung.calls.Load(); n != 3 {
+		t.Fatalf("expected 3 calls of hung divider, got %d", n)
+	}
+
+	// Calls go on once the hung ones return.
+	close(hung.release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		v, err := d.Div(4, 2)
+		if err == nil && v == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2, got %d, %v", v, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNoTimeout(t *testing.T) {
+	d := timeoutdiv.New(slowDivider(20*time.Millisecond), 0)
+	if v, err := d.Div(4, 2); err != nil || v != 2 {
+		t.Fatalf("expected 2, got %d, %v", v, err)
+	}
+}
+
+func BenchmarkTimeoutDivParallel(b *testing.B) {
+	divtest.BenchmarkParallel(b, timeoutdiv.New(godiv.Divider, time.Second))
+}
@@ -0,0 +1,115 @@
+// Package logsample provides a slog.Handler which samples log records, so
+// that a flood of similar records does not flood the log.
+package logsample
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Handler passes the first records of every category during a tick to the
+// wrapped handler and then every thereafter-th one. The category of a record
+// is its level and message. Records above the max level are never sampled
+// out. Handler is safe for concurrent use.
+type Handler struct {
+	h slog.Handler
+	s *sampler
+}
+
+type sampler struct {
+	tick       time.Duration
+	first      uint64
+	thereafter uint64
+	max        slog.Level
+
+	mu     sync.Mutex
+	counts map[category]*counter
+
+	dropped atomic.Uint64
+}
+
+type category struct {
+	level slog.Level
+	msg   string
+}
+
+type counter struct {
+	start time.Time
+	n     uint64
+}
+
+// New creates a new Handler. Zero thereafter drops all the records of the
+// category above first until the next tick.
+func New(h slog.Handler, tick time.Duration, first, thereafter uint) *Handler {
+	return &Handler{h: h, s: &sampler{
+		tick:       tick,
+		first:      uint64(first),
+		thereafter: uint64(thereafter),
+		max:        slog.Level(math.MaxInt),
+		counts:     make(map[category]*counter),
+	}}
+}
+
+// SetMaxLevel sets the max level of sampled records. By default records of
+// all levels are sampled.
+func (h *Handler) SetMaxLevel(level slog.Level) *Handler {
+	h.s.max = level
+	return h
+}
+
+// Enabled reports whether the wrapped handler handles records at the level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.h.Enabled(ctx, level)
+}
+
+// Handle passes the record to the wrapped handler unless it is sampled out.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level <= h.s.max && !h.s.sample(category{r.Level, r.Message}, r.Time) {
+		h.s.dropped.Add(1)
+		return nil
+	}
+	return h.h.Handle(ctx, r)
+}
+
+// WithAttrs returns a Handler sharing the sampling state with h.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{h: h.h.WithAttrs(attrs), s: h.s}
+}
+
+// WithGroup returns a Handler sharing the sampling state with h.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{h: h.h.WithGroup(name), s: h.s}
+}
+
+// Dropped returns the count of records sampled out.
+func (h *Handler) Dropped() uint64 {
+	return h.s.dropped.Load()
+}
+
+func (s *sampler) sample(c category, t time.Time) bool {
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cnt, ok := s.counts[c]
+	if !ok {
+		cnt = &counter{start: t}
+		s.counts[c] = cnt
+	}
+	if t.Sub(cnt.start) >= s.tick {
+		cnt.start, cnt.n = t, 0
+	}
+	cnt.n++
+
+	if cnt.n <= s.first {
+		return true
+	}
+	return s.thereafter > 0 && (cnt.n-s.first)%s.thereafter == 0
+}
@@ -0,0 +1,71 @@
+package logsample_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/b-2019-apt-test/divider/pkg/logsample"
+)
+
+func TestHandler(t *testing.T) {
+
+	var buf bytes.Buffer
+	h := logsample.New(slog.NewTextHandler(&buf, nil), time.Hour, 2, 3)
+	logger := slog.New(h).With("method", "go")
+
+	for i := 0; i < 10; i++ {
+		logger.Warn("job rejected", "job_id", i)
+	}
+	logger.Info("processing complete")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 records, got:\n%s", buf.String())
+	}
+	for i, id := range []string{"job_id=0", "job_id=1", "job_id=4", "job_id=7"} {
+		if !strings.Contains(lines[i], id) || !strings.Contains(lines[i], "method=go") {
+			t.Fatalf("record %d: expected %s, got: %s", i, id, lines[i])
+		}
+	}
+	if h.Dropped() != 6 {
+		t.Fatalf("expected 6 dropped records, got %d", h.Dropped())
+	}
+}
+
+func TestHandlerTick(t *testing.T) {
+
+	var buf bytes.Buffer
+	h := logsample.New(slog.NewTextHandler(&buf, nil), 10*time.Millisecond, 1, 0)
+	logger := slog.New(h)
+
+	logger.Warn("job rejected")
+	logger.Warn("job rejected")
+	time.Sleep(20 * time.Millisecond)
+	logger.Warn("job rejected")
+
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Fatalf("expected 2 records, got:\n%s", buf.String())
+	}
+}
+
+func TestHandlerMaxLevel(t *testing.T) {
+
+	var buf bytes.Buffer
+	h := logsample.New(slog.NewTextHandler(&buf, nil), time.Hour, 1, 0).SetMaxLevel(slog.LevelWarn)
+	logger := slog.New(h)
+
+	for i := 0; i < 3; i++ {
+		logger.Warn("job rejected", "job_id", i)
+		logger.Error("write failed", "job_id", i)
+	}
+
+	if n := strings.Count(buf.String(), "job rejected"); n != 1 {
+		t.Fatalf("expected 1 warn record, got:\n%s", buf.String())
+	}
+	if n := strings.Count(buf.String(), "write failed"); n != 3 {
+		t.Fatalf("expected 3 error records, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,75 @@
+package pool
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// FallibleWorker is a pool worker which can fail to process a job.
+type FallibleWorker interface {
+	TryProcess(job interface{}) (result interface{}, err error)
+}
+
+// Failure is delivered to the consumer in place of a result if the worker
+// failed to process the job: returned an error or panicked.
+type Failure struct {
+	Job interface{}
+	Err error
+}
+
+func (f *Failure) Error() string {
+	return f.Err.Error()
+}
+
+// Unwrap returns the cause of the failure.
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// PanicError is the cause of Failure if the worker panicked. Stack holds the
+// trace of the panicked goroutine.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("worker panicked: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Fallible adapts FallibleWorker to Worker. Errors returned by the worker are
+// delivered to the consumer as *Failure results.
+func Fallible(w FallibleWorker) Worker {
+	return fallible{w}
+}
+
+type fallible struct {
+	w FallibleWorker
+}
+
+func (f fallible) Process(job interface{}) interface{} {
+	result, err := f.w.TryProcess(job)
+	if err != nil {
+		return &Failure{Job: job, Err: err}
+	}
+	return result
+}
+
+// call processes the job and recovers the worker panic, so a single bad job
+// does not bring the whole process down.
+func call(w Worker, job interface{}) (result interface{}) {
+	defer func() {
+		if v := recover(); v != nil {
+			result = &Failure{
+				Job: job,
+				Err: &PanicError{Value: v, Stack: debug.Stack()}}
+		}
+	}()
+	return w.Process(job)
+}
@@ -0,0 +1,56 @@
+package pool
+
+import "context"
+
+// Future is a pending result of a job put into the pool with Submit.
+type Future struct {
+	job    interface{}
+	result interface{}
+	done   chan struct{}
+}
+
+// Submit puts the job into the pool and returns the future of its result.
+// The result is delivered to the future only, it does not appear among
+// results read with Consume or ConsumeChunks. The call will be blocked, if
+// all workers are busy. No jobs must be submitted after the pool is closed.
+func (p *Pool) Submit(job interface{}) *Future {
+	f := &Future{job: job, done: make(chan struct{})}
+	p.put.Add(1)
+	p.send(f)
+	return f
+}
+
+// Done returns a channel that is closed when the result is ready.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result returns the result of the job or nil if it is not ready yet. If the
+// worker failed to process the job the result is *Failure.
+func (f *Future) Result() interface{} {
+	select {
+	case <-f.done:
+		return f.result
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the result is ready or ctx is done. If the worker failed
+// to process the job, Wait returns *Failure as the error.
+func (f *Future) Wait(ctx context.Context) (interface{}, error) {
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if failure, ok := f.result.(*Failure); ok {
+		return nil, failure
+	}
+	return f.result, nil
+}
+
+func (f *Future) resolve(result interface{}) {
+	f.result = result
+	close(f.done)
+}
@@ -1,35 +1,128 @@
 package pool
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultFlushInterval is the idle timeout after which a partially filled
+// chunk is handed over to workers when the pool runs in chunked mode.
+const DefaultFlushInterval = 10 * time.Millisecond
 
 // Worker is a dummy pool worker which processes some jobs and produces
-// some results.
+// some results. If Process panics, the pool recovers and delivers *Failure
+// with *PanicError instead of the result.
 type Worker interface {
 	Process(job interface{}) (result interface{})
 }
 
+// Limiter limits the rate of job processing. Wait blocks until the next job
+// is allowed to be processed and returns the time spent waiting.
+type Limiter interface {
+	Wait() time.Duration
+}
+
+// Option configures a Pool.
+type Option func(*Pool)
+
 // Pool represents a poor man worker pool.
 type Pool struct {
-	jobs chan interface{}
-	wg   sync.WaitGroup
-	c    chan interface{}
+	q     queue
+	steal bool
+	wg    sync.WaitGroup
+	c     chan interface{}
+
+	// statistics
+	put        atomic.Uint64
+	putBlocked atomic.Int64
+	workers    []*workerStats
+	limiter    Limiter
+
+	// chunked mode
+	size    int
+	flush   time.Duration
+	mu      sync.Mutex
+	buf     []interface{}
+	cc      chan []interface{}
+	quit    chan struct{}
+	flushed chan struct{}
+	once    sync.Once
+}
+
+// ChunkSize makes the pool move jobs and results in chunks of n elements
+// instead of one by one. Chunks that are not filled up are handed over to
+// workers on Close or after the flush interval passed without new jobs.
+// Values less than 2 disable chunking.
+func ChunkSize(n uint) Option {
+	return func(p *Pool) {
+		p.size = int(n)
+	}
+}
+
+// FlushInterval specifies how long a partially filled chunk may wait for new
+// jobs before it is handed over to workers. Zero interval disables idle
+// flushing: partial chunk will be handed over only on Close. The option has
+// effect only in chunked mode. Default is DefaultFlushInterval.
+func FlushInterval(d time.Duration) Option {
+	return func(p *Pool) {
+		p.flush = d
+	}
+}
+
+// Limit makes workers wait for limiter l before processing of every job.
+func Limit(l Limiter) Option {
+	return func(p *Pool) {
+		p.limiter = l
+	}
+}
+
+// WorkStealing makes the pool use a scheduler with a local queue per worker
+// instead of the single channel shared by all workers. Put spreads jobs over
+// local queues, idle workers steal jobs from queues of busy ones. It reduces
+// contention when the pool has many workers.
+func WorkStealing() Option {
+	return func(p *Pool) {
+		p.steal = true
+	}
 }
 
 // New creates a pool with wc count of workers.
-func New(w Worker, wc uint) *Pool {
+func New(w Worker, wc uint, opts ...Option) *Pool {
 
 	if wc == 0 {
 		wc = 1
 	}
 
-	pool := &Pool{
-		jobs: make(chan interface{}, wc),
-		c:    make(chan interface{})}
+	pool := &Pool{flush: DefaultFlushInterval}
+
+	for _, opt := range opts {
+		opt(pool)
+	}
+
+	if pool.steal {
+		pool.q = newStealQueue(int(wc))
+	} else {
+		pool.q = make(chanQueue, wc)
+	}
+
+	if pool.chunked() {
+		pool.buf = make([]interface{}, 0, pool.size)
+		pool.cc = make(chan []interface{})
+		if pool.flush > 0 {
+			pool.quit = make(chan struct{})
+			pool.flushed = make(chan struct{})
+			go pool.flusher()
+		}
+	} else {
+		pool.c = make(chan interface{})
+	}
 
-	for wc > 0 {
+	pool.workers = make([]*workerStats, wc)
+	for i := range pool.workers {
+		pool.workers[i] = newWorkerStats(pool.limiter)
 		pool.wg.Add(1)
-		wc--
-		go pool.run(w)
+		go pool.run(w, i)
 	}
 
 	return pool
@@ -39,25 +132,191 @@ func New(w Worker, wc uint) *Pool {
 // jobs will be processed and only then the pool will close consumer chan and
 // finish the work. No jobs must be put into the pool after the close.
 func (p *Pool) Close() {
-	close(p.jobs)
+	if p.chunked() {
+		if p.quit != nil {
+			close(p.quit)
+			<-p.flushed
+		}
+		if len(p.buf) > 0 {
+			p.send(p.buf)
+			p.buf = nil
+		}
+	}
+	p.q.close()
 	p.wg.Wait()
-	close(p.c)
+	if p.chunked() {
+		close(p.cc)
+	} else {
+		close(p.c)
+	}
 }
 
 // Put adds the job to the pool. The call will be blocked,
 // if all workers are busy.
 func (p *Pool) Put(job interface{}) {
-	p.jobs <- job
+	p.put.Add(1)
+	if !p.chunked() {
+		p.send(job)
+		return
+	}
+
+	p.mu.Lock()
+	p.buf = append(p.buf, job)
+	if len(p.buf) < p.size {
+		p.mu.Unlock()
+		return
+	}
+	chunk := p.buf
+	p.buf = make([]interface{}, 0, p.size)
+	p.mu.Unlock()
+
+	p.send(chunk)
+}
+
+// send puts x into the queue and accounts the time spent blocked.
+func (p *Pool) send(x interface{}) {
+	if !p.q.tryPut(x) {
+		t := time.Now()
+		p.q.put(x)
+		p.putBlocked.Add(int64(time.Since(t)))
+	}
 }
 
 // Consume is a convenience wrapper for result channel of the pool.
+//
+// In chunked mode results are unpacked from chunks, which is less efficient
+// than reading them with ConsumeChunks. Consume and ConsumeChunks must not be
+// used together.
 func (p *Pool) Consume() <-chan interface{} {
+	if p.chunked() {
+		p.once.Do(func() {
+			p.c = make(chan interface{})
+			go p.unpack()
+		})
+	}
 	return p.c
 }
 
-func (p *Pool) run(w Worker) {
-	for job := range p.jobs {
-		p.c <- w.Process(job)
+// ConsumeChunks returns a channel of result chunks. The receiver owns the
+// chunk and may modify it.
+//
+// If the pool is not in chunked mode, every result is delivered in a chunk of
+// its own. Consume and ConsumeChunks must not be used together.
+func (p *Pool) ConsumeChunks() <-chan []interface{} {
+	if !p.chunked() {
+		p.once.Do(func() {
+			p.cc = make(chan []interface{})
+			go p.pack()
+		})
+	}
+	return p.cc
+}
+
+func (p *Pool) chunked() bool {
+	return p.size > 1
+}
+
+func (p *Pool) run(w Worker, id int) {
+	defer p.wg.Done()
+
+	ws := p.workers[id]
+	for {
+		x, ok := p.q.get(id)
+		if !ok {
+			return
+		}
+		switch job := x.(type) {
+		case *Future:
+			ws.taken.Add(1)
+			result, _ := ws.process(w, job.job, time.Now())
+			job.resolve(result)
+			ws.delivered.Add(1)
+		case []interface{}:
+			if p.chunked() {
+				p.processChunk(w, ws, job)
+				continue
+			}
+			p.processJob(w, ws, job)
+		default:
+			p.processJob(w, ws, job)
+		}
+	}
+}
+
+func (p *Pool) processJob(w Worker, ws *workerStats, job interface{}) {
+	ws.taken.Add(1)
+	result, _ := ws.process(w, job, time.Now())
+	select {
+	case p.c <- result:
+	default:
+		t := time.Now()
+		p.c <- result
+		ws.blocked.Add(int64(time.Since(t)))
+	}
+	ws.delivered.Add(1)
+}
+
+// processChunk writes results in place of jobs, so the chunk travels back to
+// the consumer without extra allocations.
+func (p *Pool) processChunk(w Worker, ws *workerStats, chunk []interface{}) {
+	ws.taken.Add(uint64(len(chunk)))
+	t := time.Now()
+	for i := range chunk {
+		chunk[i], t = ws.process(w, chunk[i], t)
+	}
+	select {
+	case p.cc <- chunk:
+	default:
+		t := time.Now()
+		p.cc <- chunk
+		ws.blocked.Add(int64(time.Since(t)))
+	}
+	ws.delivered.Add(uint64(len(chunk)))
+}
+
+// flusher hands over a partially filled chunk if no jobs were put into the
+// pool during the last flush interval.
+func (p *Pool) flusher() {
+	defer close(p.flushed)
+
+	t := time.NewTicker(p.flush)
+	defer t.Stop()
+
+	var last uint64
+	for {
+		select {
+		case <-p.quit:
+			return
+		case <-t.C:
+			var chunk []interface{}
+			put := p.put.Load()
+			p.mu.Lock()
+			if put == last && len(p.buf) > 0 {
+				chunk = p.buf
+				p.buf = make([]interface{}, 0, p.size)
+			}
+			p.mu.Unlock()
+			last = put
+
+			if chunk != nil {
+				p.send(chunk)
+			}
+		}
+	}
+}
+
+func (p *Pool) unpack() {
+	for chunk := range p.cc {
+		for _, result := range chunk {
+			p.c <- result
+		}
+	}
+	close(p.c)
+}
+
+func (p *Pool) pack() {
+	for result := range p.c {
+		p.cc <- []interface{}{result}
 	}
-	p.wg.Done()
+	close(p.cc)
 }
@@ -1,9 +1,14 @@
 package pool_test
 
 import (
+	"context"
+	"errors"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/b-2019-apt-test/divider/pkg/pool"
+	"github.com/b-2019-apt-test/divider/pkg/ratelimit"
 )
 
 const testPoolJobs = 1000
@@ -35,3 +40,331 @@ func TestPool(t *testing.T) {
 		t.Fatal("not all results received", results)
 	}
 }
+
+func TestPoolChunked(t *testing.T) {
+
+	p := pool.New(new(TestPoolWorker), 4, pool.ChunkSize(64))
+	jobs := testPoolJobs
+
+	go func() {
+		for i := 0; i < jobs; i++ {
+			p.Put(i)
+		}
+		p.Close()
+	}()
+
+	seen := make(map[int]bool)
+	for chunk := range p.ConsumeChunks() {
+		for _, result := range chunk {
+			seen[result.(int)] = true
+		}
+	}
+
+	if len(seen) != testPoolJobs {
+		t.Fatalf("expected %d results, got: %d", testPoolJobs, len(seen))
+	}
+}
+
+func TestPoolChunkedConsume(t *testing.T) {
+
+	p := pool.New(new(TestPoolWorker), 4, pool.ChunkSize(64))
+	results := 0
+
+	go func() {
+		for i := 0; i < testPoolJobs; i++ {
+			p.Put(i)
+		}
+		p.Close()
+	}()
+
+	for range p.Consume() {
+		results++
+	}
+
+	if results != testPoolJobs {
+		t.Fatalf("expected %d results, got: %d", testPoolJobs, results)
+	}
+}
+
+func TestPoolChunkedIdleFlush(t *testing.T) {
+
+	p := pool.New(new(TestPoolWorker), 1,
+		pool.ChunkSize(64), pool.FlushInterval(time.Millisecond))
+	defer p.Close()
+
+	p.Put(1)
+	p.Put(2)
+
+	select {
+	case chunk := <-p.ConsumeChunks():
+		if len(chunk) != 2 {
+			t.Fatalf("expected chunk of 2 results, got: %v", chunk)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("partial chunk was not flushed")
+	}
+}
+
+func BenchmarkPool(b *testing.B) {
+	p := pool.New(new(TestPoolWorker), uint(runtime.NumCPU()))
+	go putAndClose(p, b.N)
+	for range p.Consume() {
+	}
+}
+
+func BenchmarkPoolChunked(b *testing.B) {
+	p := pool.New(new(TestPoolWorker), uint(runtime.NumCPU()), pool.ChunkSize(256))
+	go putAndClose(p, b.N)
+	for range p.ConsumeChunks() {
+	}
+}
+
+func putAndClose(p *pool.Pool, n int) {
+	for i := 0; i < n; i++ {
+		p.Put(i)
+	}
+	p.Close()
+}
+
+type slowPoolWorker struct{}
+
+func (w slowPoolWorker) Process(job interface{}) interface{} {
+	time.Sleep(time.Millisecond)
+	return job
+}
+
+func TestPoolStats(t *testing.T) {
+
+	p := pool.New(slowPoolWorker{}, 2)
+
+	if s := p.Stats(); s.Workers != 2 || s.IdleWorkers != 2 {
+		t.Fatalf("unexpected stats of the idle pool: %+v", s)
+	}
+
+	go putAndClose(p, testPoolJobs/10)
+
+	// Let the results pile up to make workers wait for delivery.
+	time.Sleep(10 * time.Millisecond)
+	for range p.Consume() {
+	}
+
+	s := p.Stats()
+	if s.Completed != testPoolJobs/10 {
+		t.Fatalf("expected %d completed jobs, got: %+v", testPoolJobs/10, s)
+	}
+	if s.Queued != 0 || s.InFlight != 0 || s.IdleWorkers != 2 {
+		t.Fatalf("unexpected stats of the closed pool: %+v", s)
+	}
+	if s.Latency.Count != s.Completed {
+		t.Fatalf("expected %d latency observations, got: %d", s.Completed, s.Latency.Count)
+	}
+	if q := s.Latency.Quantile(0.5); q < time.Millisecond {
+		t.Fatalf("median latency %v is less than worker delay", q)
+	}
+	if s.PutBlocked == 0 || s.DeliverBlocked == 0 {
+		t.Fatalf("blocking time not accounted: %+v", s)
+	}
+}
+
+func TestPoolLimit(t *testing.T) {
+
+	l := ratelimit.New(1000, 1)
+	p := pool.New(new(TestPoolWorker), 4, pool.Limit(l))
+
+	go putAndClose(p, 20)
+	for range p.Consume() {
+	}
+
+	if s := p.Stats(); s.Throttled == 0 || s.Throttled != l.Waited() {
+		t.Fatalf("throttling time %v does not match limiter's %v", s.Throttled, l.Waited())
+	}
+}
+
+type panicPoolWorker struct{}
+
+func (w panicPoolWorker) Process(job interface{}) interface{} {
+	if job.(int)%10 == 0 {
+		panic("bad job")
+	}
+	return job
+}
+
+type falliblePoolWorker struct{}
+
+func (w falliblePoolWorker) TryProcess(job interface{}) (interface{}, error) {
+	if job.(int)%10 == 0 {
+		return nil, errors.New("bad job")
+	}
+	return job, nil
+}
+
+func TestPoolPanic(t *testing.T) {
+
+	p := pool.New(panicPoolWorker{}, 4, pool.ChunkSize(16))
+	go putAndClose(p, testPoolJobs)
+
+	var results, failures int
+	for result := range p.Consume() {
+		results++
+		f, ok := result.(*pool.Failure)
+		if !ok {
+			continue
+		}
+		failures++
+		var perr *pool.PanicError
+		if !errors.As(f, &perr) || len(perr.Stack) == 0 {
+			t.Fatalf("failure is not a panic with stack: %#v", f.Err)
+		}
+		if f.Job.(int)%10 != 0 {
+			t.Fatalf("unexpected failed job: %v", f.Job)
+		}
+	}
+
+	if results != testPoolJobs || failures != testPoolJobs/10 {
+		t.Fatalf("expected %d results with %d failures, got: %d, %d",
+			testPoolJobs, testPoolJobs/10, results, failures)
+	}
+}
+
+func TestPoolFallible(t *testing.T) {
+
+	p := pool.New(pool.Fallible(falliblePoolWorker{}), 4)
+	go putAndClose(p, testPoolJobs)
+
+	var failures int
+	for result := range p.Consume() {
+		if f, ok := result.(*pool.Failure); ok {
+			if f.Job.(int)%10 != 0 || f.Err == nil {
+				t.Fatalf("unexpected failure: %#v", f)
+			}
+			failures++
+		}
+	}
+
+	if failures != testPoolJobs/10 {
+		t.Fatalf("expected %d failures, got: %d", testPoolJobs/10, failures)
+	}
+}
+
+func TestPoolSubmit(t *testing.T) {
+
+	for _, opts := range [][]pool.Option{nil, {pool.ChunkSize(16)}} {
+
+		p := pool.New(new(TestPoolWorker), 4, opts...)
+
+		futures := make([]*pool.Future, 0, testPoolJobs)
+		done := make(chan int)
+		go func() {
+			results := 0
+			for range p.Consume() {
+				results++
+			}
+			done <- results
+		}()
+
+		for i := 0; i < testPoolJobs; i++ {
+			p.Put(i)
+			futures = append(futures, p.Submit(i))
+		}
+
+		for i, f := range futures {
+			result, err := f.Wait(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.(int) != i || f.Result().(int) != i {
+				t.Fatalf("future %d got result of another job: %v", i, result)
+			}
+		}
+
+		p.Close()
+		if results := <-done; results != testPoolJobs {
+			t.Fatalf("expected %d consumed results, got: %d", testPoolJobs, results)
+		}
+	}
+}
+
+func TestFutureWaitCanceled(t *testing.T) {
+
+	p := pool.New(slowPoolWorker{}, 1)
+	defer p.Close()
+
+	f := p.Submit(1)
+	if f.Result() != nil {
+		t.Fatal("result of the pending job is not nil")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Wait(ctx); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+
+	<-f.Done()
+	if f.Result().(int) != 1 {
+		t.Fatalf("unexpected result: %v", f.Result())
+	}
+}
+
+func TestFutureFailure(t *testing.T) {
+
+	p := pool.New(panicPoolWorker{}, 1)
+	defer p.Close()
+
+	if _, err := p.Submit(10).Wait(context.Background()); err == nil {
+		t.Fatal("worker panic not returned by Wait")
+	}
+}
+
+func TestPoolWorkStealing(t *testing.T) {
+
+	for _, opts := range [][]pool.Option{
+		{pool.WorkStealing()},
+		{pool.WorkStealing(), pool.ChunkSize(16)},
+	} {
+		for _, wc := range []uint{1, 3, 64} {
+
+			p := pool.New(new(TestPoolWorker), wc, opts...)
+			go putAndClose(p, testPoolJobs*10)
+
+			seen := make(map[int]bool)
+			for result := range p.Consume() {
+				if seen[result.(int)] {
+					t.Fatalf("result %v delivered twice", result)
+				}
+				seen[result.(int)] = true
+			}
+
+			if len(seen) != testPoolJobs*10 {
+				t.Fatalf("%d workers: expected %d results, got: %d",
+					wc, testPoolJobs*10, len(seen))
+			}
+			if s := p.Stats(); s.Completed != testPoolJobs*10 || s.Queued != 0 {
+				t.Fatalf("unexpected stats: %+v", s)
+			}
+		}
+	}
+}
+
+func TestPoolWorkStealingSubmit(t *testing.T) {
+
+	p := pool.New(slowPoolWorker{}, 8, pool.WorkStealing())
+	defer p.Close()
+
+	futures := make([]*pool.Future, 100)
+	for i := range futures {
+		futures[i] = p.Submit(i)
+	}
+	for i, f := range futures {
+		if result, err := f.Wait(context.Background()); err != nil || result.(int) != i {
+			t.Fatalf("future %d: unexpected result %v, err: %v", i, result, err)
+		}
+	}
+}
+
+func BenchmarkPoolWorkStealing(b *testing.B) {
+	p := pool.New(new(TestPoolWorker), uint(runtime.NumCPU()), pool.WorkStealing())
+	go putAndClose(p, b.N)
+	for range p.Consume() {
+	}
+}
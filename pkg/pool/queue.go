@@ -0,0 +1,329 @@
+package pool
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// localQueueSize is the capacity of a worker's local queue in work-stealing
+// mode.
+const localQueueSize = 4
+
+// queue distributes jobs among workers.
+type queue interface {
+	// tryPut adds x to the queue unless it has to wait for free space.
+	tryPut(x interface{}) bool
+	// put adds x to the queue, waiting for free space if necessary.
+	put(x interface{})
+	// get returns the next element for the worker. It returns false once
+	// the queue is closed and drained.
+	get(worker int) (interface{}, bool)
+	close()
+}
+
+// chanQueue is a single channel shared by all workers.
+type chanQueue chan interface{}
+
+func (q chanQueue) tryPut(x interface{}) bool {
+	select {
+	case q <- x:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q chanQueue) put(x interface{}) {
+	q <- x
+}
+
+func (q chanQueue) get(int) (interface{}, bool) {
+	x, ok := <-q
+	return x, ok
+}
+
+func (q chanQueue) close() {
+	close(q)
+}
+
+// stealQueue keeps a local queue per worker. Put hands elements to parked
+// workers first, and fills local queues one by one when all workers are
+// busy, waking a parked worker to steal. A worker takes elements from its own queue
+// first and steals half of another worker's queue when its own is empty.
+// Stolen elements are kept in the worker's private stash. Workers park only
+// when there is nothing to take at all.
+type stealQueue struct {
+	locals []*localQueue
+	stash  [][]interface{}
+	wake   []chan struct{}
+	next   atomic.Uint64
+
+	// nonEmpty is a bitmap of local queues having elements, so thieves
+	// don't have to probe every queue.
+	nonEmpty []atomic.Uint64
+
+	// n is the count of elements in local queues.
+	n atomic.Int64
+
+	// mu guards parked and closed.
+	mu      sync.Mutex
+	parked  []int
+	nparked atomic.Int64
+	closed  bool
+
+	notFull *sync.Cond
+	full    atomic.Int64
+}
+
+func newStealQueue(workers int) *stealQueue {
+	q := &stealQueue{
+		locals: make([]*localQueue, workers),
+		stash:  make([][]interface{}, workers),
+		wake:   make([]chan struct{}, workers),
+		parked: make([]int, 0, workers)}
+	q.nonEmpty = make([]atomic.Uint64, (workers+63)/64)
+	for i := range q.locals {
+		q.locals[i] = &localQueue{
+			bitmap: &q.nonEmpty[i/64],
+			bit:    1 << uint(i%64)}
+		q.stash[i] = make([]interface{}, 0, localQueueSize)
+		q.wake[i] = make(chan struct{}, 1)
+	}
+	q.notFull = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *stealQueue) tryPut(x interface{}) bool {
+	if q.nparked.Load() > 0 {
+		if w, ok := q.unpark(); ok {
+			if q.locals[w].push(x) {
+				q.n.Add(1)
+				q.wake[w] <- struct{}{}
+				return true
+			}
+			// The queue of the parked worker has been filled
+			// by previous puts meanwhile, wake the worker to
+			// process them.
+			q.wake[w] <- struct{}{}
+		}
+	}
+
+	if !q.push(x) {
+		return false
+	}
+	if q.nparked.Load() > 0 {
+		if w, ok := q.unpark(); ok {
+			q.wake[w] <- struct{}{}
+		}
+	}
+	return true
+}
+
+func (q *stealQueue) put(x interface{}) {
+	if q.tryPut(x) {
+		return
+	}
+	q.mu.Lock()
+	q.full.Add(1)
+	for !q.push(x) {
+		q.notFull.Wait()
+	}
+	q.full.Add(-1)
+	q.mu.Unlock()
+	if w, ok := q.unpark(); ok {
+		q.wake[w] <- struct{}{}
+	}
+}
+
+func (q *stealQueue) get(worker int) (interface{}, bool) {
+	for {
+		if x, ok := q.take(worker); ok {
+			return x, true
+		}
+
+		// The worker is registered as parked before checking n,
+		// while Put updates n before checking parked workers,
+		// so either the worker sees the element or Put sees the
+		// parked worker.
+		q.mu.Lock()
+		q.parked = append(q.parked, worker)
+		q.nparked.Add(1)
+		if q.n.Load() > 0 || q.closed {
+			q.parked = q.parked[:len(q.parked)-1]
+			q.nparked.Add(-1)
+			drained := q.closed && q.n.Load() == 0
+			q.mu.Unlock()
+			if drained {
+				return nil, false
+			}
+			continue
+		}
+		q.mu.Unlock()
+
+		<-q.wake[worker]
+	}
+}
+
+func (q *stealQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	for _, w := range q.parked {
+		q.wake[w] <- struct{}{}
+	}
+	q.parked = q.parked[:0]
+	q.nparked.Store(0)
+	q.mu.Unlock()
+}
+
+// unpark removes the most recently parked worker from the parked list.
+// The caller must wake the worker.
+func (q *stealQueue) unpark() (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.parked) == 0 {
+		return 0, false
+	}
+	w := q.parked[len(q.parked)-1]
+	q.parked = q.parked[:len(q.parked)-1]
+	q.nparked.Add(-1)
+	return w, true
+}
+
+// push adds x to the current local queue, moving on to the next ones when
+// it is full. Filling queues one by one keeps non-empty queues together, so
+// thieves find them quickly.
+func (q *stealQueue) push(x interface{}) bool {
+	start := q.next.Load()
+	for i := uint64(0); i < uint64(len(q.locals)); i++ {
+		if q.locals[(start+i)%uint64(len(q.locals))].push(x) {
+			if i > 0 {
+				q.next.Store(start + i)
+			}
+			q.n.Add(1)
+			return true
+		}
+	}
+	return false
+}
+
+// take pops an element from the worker's stash or own queue, or steals
+// from others. Stashed elements are not counted in n, so they don't keep
+// other workers from parking.
+func (q *stealQueue) take(worker int) (interface{}, bool) {
+	if stash := q.stash[worker]; len(stash) > 0 {
+		x := stash[len(stash)-1]
+		stash[len(stash)-1] = nil
+		q.stash[worker] = stash[:len(stash)-1]
+		return x, true
+	}
+	if q.n.Load() == 0 {
+		return nil, false
+	}
+
+	x, ok := q.locals[worker].pop()
+	if !ok {
+		x, ok = q.steal(worker)
+	}
+	if !ok {
+		return nil, false
+	}
+
+	q.n.Add(-int64(1 + len(q.stash[worker])))
+	if q.full.Load() > 0 {
+		q.mu.Lock()
+		q.notFull.Broadcast()
+		q.mu.Unlock()
+	}
+	return x, true
+}
+
+// steal looks up non-empty queues of other workers in the bitmap starting
+// from the worker's neighbours and steals from the first one that still has
+// elements.
+func (q *stealQueue) steal(worker int) (interface{}, bool) {
+	start := worker / 64
+	for i := 0; i <= len(q.nonEmpty); i++ {
+		word := (start + i) % len(q.nonEmpty)
+		set := q.nonEmpty[word].Load()
+		if word == start {
+			// The own queue is empty, and the words are
+			// visited in order, so the own word is probed
+			// twice to cover bits on both sides.
+			own := uint(worker % 64)
+			if i == 0 {
+				set &= ^uint64(0) << own
+			} else {
+				set &= 1<<own - 1
+			}
+			set &^= 1 << own
+		}
+		for set != 0 {
+			b := bits.TrailingZeros64(set)
+			set &^= 1 << uint(b)
+			victim := q.locals[word*64+b]
+			if x, ok := victim.steal(&q.stash[worker]); ok {
+				return x, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// localQueue is a bounded FIFO ring buffer. The queue maintains its bit in
+// the bitmap of non-empty queues.
+type localQueue struct {
+	mu     sync.Mutex
+	items  [localQueueSize]interface{}
+	head   int
+	len    int
+	bitmap *atomic.Uint64
+	bit    uint64
+}
+
+func (l *localQueue) push(x interface{}) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.len == len(l.items) {
+		return false
+	}
+	l.items[(l.head+l.len)%len(l.items)] = x
+	l.len++
+	if l.len == 1 {
+		l.bitmap.Or(l.bit)
+	}
+	return true
+}
+
+func (l *localQueue) pop() (interface{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.popLocked()
+}
+
+func (l *localQueue) popLocked() (interface{}, bool) {
+	if l.len == 0 {
+		return nil, false
+	}
+	x := l.items[l.head]
+	l.items[l.head] = nil
+	l.head = (l.head + 1) % len(l.items)
+	l.len--
+	if l.len == 0 {
+		l.bitmap.And(^l.bit)
+	}
+	return x, true
+}
+
+// steal takes the first element of l to be returned and moves up to half
+// of the rest to the thief's stash.
+func (l *localQueue) steal(stash *[]interface{}) (interface{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	x, ok := l.popLocked()
+	for n := l.len / 2; ok && n > 0; n-- {
+		y, _ := l.popLocked()
+		*stash = append(*stash, y)
+	}
+	return x, ok
+}
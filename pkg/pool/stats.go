@@ -0,0 +1,177 @@
+package pool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are upper bounds of the job processing latency histogram
+// buckets.
+var LatencyBuckets = []time.Duration{
+	time.Microsecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// Stats is a snapshot of the pool counters. Counters are read one by one
+// without stopping the pool, so a snapshot of a running pool is not
+// necessarily consistent.
+type Stats struct {
+	// Queued is the count of jobs put into the pool, but not taken by
+	// workers yet.
+	Queued uint64
+	// InFlight is the count of jobs taken by workers, which results are not
+	// delivered to the consumer yet.
+	InFlight uint64
+	// Completed is the count of delivered results.
+	Completed uint64
+	// Workers is the total count of workers.
+	Workers uint64
+	// IdleWorkers is the count of workers waiting for jobs.
+	IdleWorkers uint64
+	// PutBlocked is the total time Put spent waiting for workers.
+	PutBlocked time.Duration
+	// DeliverBlocked is the total time workers spent waiting for the
+	// consumer to receive results.
+	DeliverBlocked time.Duration
+	// Throttled is the total time workers spent waiting for the limiter.
+	Throttled time.Duration
+	// Latency is the distribution of Worker.Process call durations.
+	Latency Histogram
+}
+
+// Histogram is a snapshot of a latency distribution. Counts[i] is the count
+// of observations in range (Bounds[i-1], Bounds[i]], the last element of
+// Counts holds observations greater than all the bounds.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// Mean returns the average of observations.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns an upper bound of the bucket containing q-quantile of
+// observations. For observations above all bounds the last bound is returned.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 || len(h.Bounds) == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(h.Count))
+	var n uint64
+	for i, c := range h.Counts {
+		n += c
+		if n > rank || n == h.Count {
+			if i >= len(h.Bounds) {
+				i = len(h.Bounds) - 1
+			}
+			return h.Bounds[i]
+		}
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+// Stats returns live counters of the pool.
+func (p *Pool) Stats() Stats {
+
+	s := Stats{
+		Workers:    uint64(len(p.workers)),
+		PutBlocked: time.Duration(p.putBlocked.Load()),
+		Latency: Histogram{
+			Bounds: LatencyBuckets,
+			Counts: make([]uint64, len(LatencyBuckets)+1)}}
+
+	var taken uint64
+	for _, ws := range p.workers {
+		delivered := ws.delivered.Load()
+		t := ws.taken.Load()
+		if t == delivered {
+			s.IdleWorkers++
+		}
+		taken += t
+		s.InFlight += t - delivered
+		s.Completed += delivered
+		s.DeliverBlocked += time.Duration(ws.blocked.Load())
+		s.Throttled += time.Duration(ws.throttled.Load())
+		ws.latency.add(&s.Latency)
+	}
+
+	if put := p.put.Load(); put > taken {
+		s.Queued = put - taken
+	}
+
+	return s
+}
+
+// workerStats is owned by a single worker, so updates of its counters are
+// not contended.
+type workerStats struct {
+	taken     atomic.Uint64
+	delivered atomic.Uint64
+	blocked   atomic.Int64
+	throttled atomic.Int64
+	latency   histogram
+	limiter   Limiter
+}
+
+func newWorkerStats(l Limiter) *workerStats {
+	return &workerStats{latency: newHistogram(), limiter: l}
+}
+
+// process calls the worker and observes the latency since start. The end
+// time is returned, so it can serve as the start of the next job of a chunk
+// saving a clock read. The limiter wait is not included in the latency.
+func (ws *workerStats) process(w Worker, job interface{}, start time.Time) (interface{}, time.Time) {
+	if ws.limiter != nil {
+		ws.throttled.Add(int64(ws.limiter.Wait()))
+		start = time.Now()
+	}
+	result := call(w, job)
+	end := time.Now()
+	ws.latency.observe(end.Sub(start))
+	return result, end
+}
+
+type histogram struct {
+	counts []atomic.Uint64
+	sum    atomic.Int64
+}
+
+func newHistogram() histogram {
+	return histogram{counts: make([]atomic.Uint64, len(LatencyBuckets)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *histogram) add(to *Histogram) {
+	for i := range h.counts {
+		c := h.counts[i].Load()
+		to.Counts[i] += c
+		to.Count += c
+	}
+	to.Sum += time.Duration(h.sum.Load())
+}
@@ -0,0 +1,65 @@
+// Package ratelimit provides a token bucket rate limiter.
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter is a token bucket which is refilled with rate tokens per second
+// and holds up to burst tokens. Every Wait call takes one token. Limiter is
+// safe for concurrent use.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	waited atomic.Int64
+}
+
+// New creates a new Limiter with the full bucket. Burst of zero is treated
+// as one.
+func New(rate float64, burst uint) *Limiter {
+	if burst == 0 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now()}
+}
+
+// Wait blocks until a token is available and returns the time spent waiting.
+// Tokens are reserved in order of calls, so waiting callers are served
+// first come, first served.
+func (l *Limiter) Wait() time.Duration {
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	tokens := l.tokens
+	l.mu.Unlock()
+
+	if tokens >= 0 {
+		return 0
+	}
+
+	d := time.Duration(-tokens / l.rate * float64(time.Second))
+	time.Sleep(d)
+	l.waited.Add(int64(d))
+	return d
+}
+
+// Waited returns the total time callers spent waiting for tokens.
+func (l *Limiter) Waited() time.Duration {
+	return time.Duration(l.waited.Load())
+}
@@ -0,0 +1,52 @@
+package ratelimit_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-2019-apt-test/divider/pkg/ratelimit"
+)
+
+func TestLimiterBurst(t *testing.T) {
+	l := ratelimit.New(1, 10)
+	for i := 0; i < 10; i++ {
+		if d := l.Wait(); d != 0 {
+			t.Fatalf("call %d within burst waited %v", i, d)
+		}
+	}
+	if l.Waited() != 0 {
+		t.Fatalf("unexpected waiting time: %v", l.Waited())
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+
+	const (
+		rate  = 1000
+		burst = 10
+		calls = 60
+	)
+
+	l := ratelimit.New(rate, burst)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			l.Wait()
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	// 50 calls above the burst take at least 50ms at 1000 calls per second.
+	min := time.Duration(calls-burst) * time.Second / rate
+	if d := time.Since(start); d < min*9/10 {
+		t.Fatalf("%d calls took %v, expected at least %v", calls, d, min)
+	}
+	if l.Waited() == 0 {
+		t.Fatal("waiting time not accounted")
+	}
+}